@@ -0,0 +1,440 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fréquences de récurrence supportées (RFC 5545, section 3.3.10)
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRRulePeriods borne le nombre de périodes parcourues lors d'une expansion,
+// pour qu'une règle qui ne produit jamais d'occurrence (ex: 31 février) ne boucle pas indéfiniment.
+const maxRRulePeriods = 10000
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum représente une valeur BYDAY, par exemple "FR", "1FR" ou "-1SA".
+// Un Ordinal à 0 signifie "tous les jours de ce type dans la période".
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// RRule est un sous-ensemble de RRULE (RFC 5545) suffisant pour les événements récurrents :
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY et BYMONTH.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// ParseRRule décode une règle de la forme "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE".
+// Le préfixe "RRULE:" est accepté.
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid recurrence rule part: %s", part)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch key {
+		case "FREQ":
+			switch val {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = val
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency: %s", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %s", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %s", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleTime(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL: %s", val)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, d := range strings.Split(val, ",") {
+				wd, err := parseWeekdayNum(d)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY: %s", d)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(m)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH: %s", m)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			// Seul le lundi (valeur par défaut) est supporté
+			if val != "MO" {
+				return nil, fmt.Errorf("unsupported WKST: %s", val)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part: %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("recurrence rule requires FREQ")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be used together")
+	}
+	for _, wd := range rule.ByDay {
+		if wd.Ordinal != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return nil, errors.New("BYDAY ordinals are only allowed with MONTHLY or YEARLY frequency")
+		}
+	}
+
+	return rule, nil
+}
+
+// String réencode la règle au format RFC 5545 (sans le préfixe "RRULE:").
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// String retourne la valeur BYDAY correspondante, par exemple "-1SA".
+func (w WeekdayNum) String() string {
+	for code, wd := range rruleWeekdays {
+		if wd == w.Weekday {
+			if w.Ordinal == 0 {
+				return code
+			}
+			return strconv.Itoa(w.Ordinal) + code
+		}
+	}
+	return ""
+}
+
+// Between retourne les débuts d'occurrence compris dans [from, to], en partant de dtstart.
+//
+// Les occurrences sont calculées dans le fuseau horaire de dtstart, de sorte que l'heure
+// locale reste stable lors des changements d'heure. COUNT est toujours compté depuis dtstart,
+// même si la fenêtre demandée commence plus tard.
+func (r *RRule) Between(dtstart, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	r.iterate(dtstart, func(t time.Time) bool {
+		if t.After(to) {
+			return false
+		}
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// Includes indique si t est une occurrence générée par la règle à partir de dtstart.
+func (r *RRule) Includes(dtstart, t time.Time) bool {
+	found := false
+	r.iterate(dtstart, func(o time.Time) bool {
+		if o.Equal(t) {
+			found = true
+		}
+		return o.Before(t)
+	})
+	return found
+}
+
+// CountBefore retourne le nombre d'occurrences strictement antérieures à t.
+func (r *RRule) CountBefore(dtstart, t time.Time) int {
+	n := 0
+	r.iterate(dtstart, func(o time.Time) bool {
+		if !o.Before(t) {
+			return false
+		}
+		n++
+		return true
+	})
+	return n
+}
+
+// iterate appelle yield pour chaque occurrence dans l'ordre chronologique, jusqu'à ce que
+// yield retourne false ou que la règle soit épuisée (COUNT, UNTIL ou maxRRulePeriods).
+func (r *RRule) iterate(dtstart time.Time, yield func(time.Time) bool) {
+	emitted := 0
+	for period := 0; period < maxRRulePeriods; period++ {
+		candidates := r.candidates(dtstart, period)
+		for _, c := range candidates {
+			if c.Before(dtstart) {
+				continue
+			}
+			if r.Until != nil && c.After(*r.Until) {
+				return
+			}
+			if !yield(c) {
+				return
+			}
+			emitted++
+			if r.Count > 0 && emitted >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// candidates retourne les occurrences triées de la n-ième période (jour, semaine, mois ou année).
+func (r *RRule) candidates(dtstart time.Time, n int) []time.Time {
+	step := n * r.Interval
+	var days []time.Time
+
+	switch r.Freq {
+	case FreqDaily:
+		day := dateOf(dtstart).AddDate(0, 0, step)
+		if r.matchesDay(day) {
+			days = append(days, day)
+		}
+	case FreqWeekly:
+		start := dateOf(dtstart)
+		// Début de semaine au lundi (WKST=MO)
+		offset := (int(start.Weekday()) + 6) % 7
+		weekStart := start.AddDate(0, 0, -offset+7*step)
+		if len(r.ByDay) == 0 {
+			days = append(days, weekStart.AddDate(0, 0, offset))
+		} else {
+			for i := 0; i < 7; i++ {
+				day := weekStart.AddDate(0, 0, i)
+				if r.matchesWeekday(day) {
+					days = append(days, day)
+				}
+			}
+		}
+		days = r.filterMonth(days)
+	case FreqMonthly:
+		first := time.Date(dtstart.Year(), dtstart.Month(), 1, 0, 0, 0, 0, dtstart.Location()).AddDate(0, step, 0)
+		days = r.filterMonth(r.daysInMonth(first, dtstart.Day()))
+	case FreqYearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, m := range months {
+			first := time.Date(dtstart.Year()+step, m, 1, 0, 0, 0, 0, dtstart.Location())
+			days = append(days, r.daysInMonth(first, dtstart.Day())...)
+		}
+	}
+
+	result := make([]time.Time, 0, len(days))
+	for _, d := range days {
+		result = append(result, time.Date(d.Year(), d.Month(), d.Day(),
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location()))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+// daysInMonth retourne les jours du mois commençant à first qui correspondent à BYMONTHDAY
+// et/ou BYDAY, ou defaultDay si aucun des deux n'est précisé.
+func (r *RRule) daysInMonth(first time.Time, defaultDay int) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if defaultDay <= last {
+			days = append(days, first.AddDate(0, 0, defaultDay-1))
+		}
+		return days
+	}
+
+	for d := 1; d <= last; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if len(r.ByMonthDay) > 0 && !containsMonthDay(r.ByMonthDay, d, last) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.matchesMonthWeekday(day, last) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// matchesDay applique les filtres BYMONTH, BYMONTHDAY et BYDAY à une date (fréquence DAILY).
+func (r *RRule) matchesDay(day time.Time) bool {
+	if len(r.filterMonth([]time.Time{day})) == 0 {
+		return false
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	if len(r.ByMonthDay) > 0 && !containsMonthDay(r.ByMonthDay, day.Day(), last) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.matchesWeekday(day) {
+		return false
+	}
+	return true
+}
+
+func (r *RRule) matchesWeekday(day time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthWeekday tient compte des ordinaux BYDAY (ex: 2TU = deuxième mardi du mois).
+func (r *RRule) matchesMonthWeekday(day time.Time, lastDay int) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		if wd.Ordinal == 0 {
+			return true
+		}
+		if wd.Ordinal > 0 && (day.Day()-1)/7+1 == wd.Ordinal {
+			return true
+		}
+		if wd.Ordinal < 0 && -((lastDay-day.Day())/7+1) == wd.Ordinal {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) filterMonth(days []time.Time) []time.Time {
+	if len(r.ByMonth) == 0 {
+		return days
+	}
+	filtered := days[:0]
+	for _, d := range days {
+		for _, m := range r.ByMonth {
+			if d.Month() == m {
+				filtered = append(filtered, d)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func containsMonthDay(monthDays []int, day, lastDay int) bool {
+	for _, md := range monthDays {
+		if md == day || (md < 0 && lastDay+md+1 == day) {
+			return true
+		}
+	}
+	return false
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", value)
+	}
+	code := value[len(value)-2:]
+	wd, ok := rruleWeekdays[code]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", value)
+	}
+	ordinal := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", value)
+		}
+		ordinal = n
+	}
+	return WeekdayNum{Ordinal: ordinal, Weekday: wd}, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import "strings"
+
+// DeviceLabel construit un libellé lisible ("Chrome sur Windows") à partir d'un User-Agent.
+// La détection est volontairement sommaire : elle sert uniquement à l'affichage des sessions.
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Appareil inconnu"
+	}
+	ua := strings.ToLower(userAgent)
+
+	browser := "Navigateur inconnu"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp") || strings.Contains(ua, "dart") || strings.Contains(ua, "cfnetwork"):
+		browser = "Application"
+	}
+
+	os := "système inconnu"
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ios"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	return browser + " sur " + os
+}
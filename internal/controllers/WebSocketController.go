@@ -21,5 +21,5 @@ func (ctrl *WebSocketController) WebSocketHandler(c *websocket.Conn) {
 		log.Println("WebSocket connection closed")
 	}()
 
-	ctrl.service.HandleWebSocket(c)
+	ctrl.service.HandleWebSocket(c, c.Locals("user_id").(string))
 }
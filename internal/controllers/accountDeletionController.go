@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type AccountDeletionController struct {
+	AccountDeletionService *services.AccountDeletionService
+	AuthService            *services.AuthService
+}
+
+// NewAccountDeletionController crée une nouvelle instance de AccountDeletionController
+func NewAccountDeletionController(accountDeletionService *services.AccountDeletionService, authService *services.AuthService) *AccountDeletionController {
+	return &AccountDeletionController{
+		AccountDeletionService: accountDeletionService,
+		AuthService:            authService,
+	}
+}
+
+// RequestDeletion programme la suppression du compte de l'utilisateur connecté
+// @Summary Supprimer son compte
+// @Description La suppression est effective après le délai de grâce (annulable d'ici là). Les événements publiés à venir sont transférés à l'ami indiqué (transfer_events_to) ou annulés.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]interface{}
+// @Router /api/deleteMyAccount [delete]
+func (ctrl *AccountDeletionController) RequestDeletion(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		TransferEventsTo string `json:"transfer_events_to"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	user, err := ctrl.AccountDeletionService.RequestDeletion(userID, req.TransferEventsTo)
+	if err != nil {
+		return c.Status(accountDeletionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":               "Suppression du compte programmée",
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+	})
+}
+
+// GetDeletionStatus indique si une suppression est programmée et à quelle date
+func (ctrl *AccountDeletionController) GetDeletionStatus(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	user, err := ctrl.AuthService.GetUserByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"scheduled":             user.DeletionScheduledAt != nil,
+		"deletion_requested_at": user.DeletionRequestedAt,
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+		"transfer_events_to":    user.DeletionTransferTo,
+	})
+}
+
+// CancelDeletion annule la suppression programmée du compte
+func (ctrl *AccountDeletionController) CancelDeletion(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := ctrl.AccountDeletionService.CancelDeletion(userID); err != nil {
+		return c.Status(accountDeletionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Suppression du compte annulée"})
+}
+
+// accountDeletionErrorStatus associe les erreurs de suppression de compte à un code HTTP
+func accountDeletionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrDeletionAlreadyScheduled), errors.Is(err, services.ErrDeletionNotScheduled):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrInvalidTransferTarget):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
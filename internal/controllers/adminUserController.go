@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type AdminUserController struct {
+	AdminUserService *services.AdminUserService
+}
+
+// NewAdminUserController crée une nouvelle instance de AdminUserController
+func NewAdminUserController(adminUserService *services.AdminUserService) *AdminUserController {
+	return &AdminUserController{
+		AdminUserService: adminUserService,
+	}
+}
+
+// auditActor identifie l'administrateur connecté pour le journal d'audit
+func auditActor(c *fiber.Ctx) (services.AuditActor, bool) {
+	userID, ok := c.Locals("user_id").(string)
+	return services.AuditActor{ID: userID, IP: c.IP()}, ok
+}
+
+// SearchUsers recherche des comptes
+// @Summary Rechercher des utilisateurs (administrateurs)
+// @Description Filtre par nom d'utilisateur, email ou ID (q), rôle (role) et statut (status : active, suspended, deleted)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/users [get]
+func (ctrl *AdminUserController) SearchUsers(c *fiber.Ctx) error {
+	users, total, err := ctrl.AdminUserService.SearchUsers(services.UserSearch{
+		Query:  c.Query("q"),
+		Role:   models.Role(c.Query("role")),
+		Status: c.Query("status"),
+		Limit:  c.QueryInt("limit"),
+		Offset: c.QueryInt("offset"),
+	})
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"users": users, "total": total})
+}
+
+// GetUserActivity retourne l'activité récente d'un compte (sessions, clés d'API, événements, participations, journal d'audit)
+func (ctrl *AdminUserController) GetUserActivity(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	activity, err := ctrl.AdminUserService.GetUserActivity(actor, c.Params("id"))
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(activity)
+}
+
+// ChangeRole modifie le rôle d'un compte
+func (ctrl *AdminUserController) ChangeRole(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Role   models.Role `json:"role"`
+		Reason string      `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AdminUserService.ChangeRole(actor, c.Params("id"), req.Role, req.Reason)
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+// SuspendUser suspend un compte jusqu'à une date (until), ou le bannit si until est absent
+func (ctrl *AdminUserController) SuspendUser(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AdminUserService.SuspendUser(actor, c.Params("id"), req.Reason, req.Until)
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+// ReinstateUser lève la suspension ou le bannissement d'un compte
+func (ctrl *AdminUserController) ReinstateUser(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	reason, err := parseReason(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AdminUserService.ReinstateUser(actor, c.Params("id"), reason)
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+// ForcePasswordReset impose la réinitialisation du mot de passe et révoque toutes les sessions
+func (ctrl *AdminUserController) ForcePasswordReset(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	reason, err := parseReason(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AdminUserService.ForcePasswordReset(actor, c.Params("id"), reason)
+	if err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+// UnlockUser lève le blocage de connexion d'un utilisateur après des échecs répétés
+func (ctrl *AdminUserController) UnlockUser(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	reason, err := parseReason(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := ctrl.AdminUserService.UnlockUser(actor, c.Params("id"), reason); err != nil {
+		return c.Status(adminUserErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User unlocked"})
+}
+
+// GetAuditLog retourne les dernières actions d'administration, éventuellement pour un seul compte (user_id)
+func (ctrl *AdminUserController) GetAuditLog(c *fiber.Ctx) error {
+	logs, err := ctrl.AdminUserService.GetAuditLog(c.Query("user_id"), c.QueryInt("limit"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(logs)
+}
+
+// parseReason lit le motif facultatif d'une action d'administration
+func parseReason(c *fiber.Ctx) (string, error) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return "", err
+		}
+	}
+	return req.Reason, nil
+}
+
+// adminUserErrorStatus associe les erreurs d'administration des comptes à un code HTTP
+func adminUserErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrReasonRequired),
+		errors.Is(err, services.ErrInvalidSuspensionUntil), errors.Is(err, services.ErrInvalidUserStatus):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrCannotModerateSelf), errors.Is(err, services.ErrCannotModerateAdmin):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrNotSuspended):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
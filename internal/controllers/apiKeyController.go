@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type APIKeyController struct {
+	APIKeyService *services.APIKeyService
+}
+
+// NewAPIKeyController crée une nouvelle instance de APIKeyController
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{
+		APIKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey crée une clé d'API personnelle
+// @Summary Créer une clé d'API
+// @Description Crée une clé limitée à des scopes (events:read, events:write, events:publish). La clé n'est affichée qu'une seule fois.
+// @Tags API keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} map[string]interface{}
+// @Router /api/api_keys [post]
+func (ctrl *APIKeyController) CreateAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Name      string               `json:"name"`
+		Scopes    []models.APIKeyScope `json:"scopes"`
+		ExpiresAt *time.Time           `json:"expires_at"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	plain, key, err := ctrl.APIKeyService.CreateKey(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return c.Status(apiKeyErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"key":     plain,
+		"api_key": key,
+	})
+}
+
+// GetAPIKeys liste les clés d'API de l'utilisateur connecté
+func (ctrl *APIKeyController) GetAPIKeys(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	keys, err := ctrl.APIKeyService.GetKeys(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// RevokeAPIKey révoque une clé d'API
+func (ctrl *APIKeyController) RevokeAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := ctrl.APIKeyService.RevokeKey(userID, c.Params("id")); err != nil {
+		return c.Status(apiKeyErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "API key revoked"})
+}
+
+// apiKeyErrorStatus associe les erreurs du service de clés d'API à un code HTTP
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrTooManyAPIKeys):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrInvalidAPIKeyScope), errors.Is(err, services.ErrAPIKeyNameRequired),
+		errors.Is(err, services.ErrInvalidAPIKeyExpiry):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type ArtistController struct {
+	ArtistService *services.ArtistService
+}
+
+// NewArtistController crée une nouvelle instance de ArtistController
+func NewArtistController(artistService *services.ArtistService) *ArtistController {
+	return &ArtistController{
+		ArtistService: artistService,
+	}
+}
+
+// FollowArtist permet à l'utilisateur connecté de suivre un artiste
+func (ac *ArtistController) FollowArtist(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	artistID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID d'artiste invalide"})
+	}
+
+	if err := ac.ArtistService.FollowArtist(userID, artistID); err != nil {
+		log.Printf("Échec du suivi de l'artiste %d : %v", artistID, err)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Artiste non trouvé"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Artiste suivi avec succès"})
+}
+
+// UnfollowArtist permet à l'utilisateur connecté de ne plus suivre un artiste
+func (ac *ArtistController) UnfollowArtist(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	artistID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID d'artiste invalide"})
+	}
+
+	if err := ac.ArtistService.UnfollowArtist(userID, artistID); err != nil {
+		log.Printf("Échec de l'arrêt du suivi de l'artiste %d : %v", artistID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Échec de l'arrêt du suivi"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Artiste retiré des suivis"})
+}
+
+// GetFollowedArtists retourne les artistes suivis par l'utilisateur connecté
+func (ac *ArtistController) GetFollowedArtists(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	artists, err := ac.ArtistService.GetFollowedArtists(userID)
+	if err != nil {
+		log.Printf("Échec de la récupération des artistes suivis : %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Échec de la récupération des artistes suivis"})
+	}
+
+	return c.JSON(artists)
+}
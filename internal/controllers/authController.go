@@ -1,28 +1,26 @@
 package controllers
 
 import (
-	"context"
-	"encoding/json"
-	"math/rand"
-	"time"
+	"errors"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	middlewares "github.com/mackenzii/freemusic/internal/middleware"
 	"github.com/mackenzii/freemusic/internal/models"
 	"github.com/mackenzii/freemusic/internal/services"
-	"github.com/oklog/ulid/v2"
-	"golang.org/x/oauth2"
 )
 
 type AuthController struct {
 	AuthService  *services.AuthService
 	ImageService *services.ImageService
+	OAuthService *services.OAuthService
 }
 
-func NewAuthController(authService *services.AuthService, imageService *services.ImageService) *AuthController {
+func NewAuthController(authService *services.AuthService, imageService *services.ImageService, oauthService *services.OAuthService) *AuthController {
 	return &AuthController{
 		AuthService:  authService,
 		ImageService: imageService,
+		OAuthService: oauthService,
 	}
 }
 
@@ -32,29 +30,18 @@ func (ctrl *AuthController) RegisterHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if req.Role == "" {
-		req.Role = "Fan"
-	}
-
 	userInfo := models.Users{
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: req.Password,
 		Location:     req.Location,
-		ConfirmationToken: ulid.MustNew(ulid.Timestamp(time.Now()),
-			ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)).String(),
 	}
 
-	user, err := ctrl.AuthService.RegisterUser(userInfo)
-	if err != nil {
+	// RegisterUser envoie lui-même l'email de confirmation
+	if _, err := ctrl.AuthService.RegisterUser(userInfo); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	err = ctrl.AuthService.EmailService.SendConfirmationEmail(user.Email, user.ConfirmationToken)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to send confirmation email"})
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Registration successful, please check your email"})
 }
 
@@ -63,7 +50,6 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 	Location string `json:"location"`
-	Role     string `json:"role"`
 }
 
 type UserResponse struct {
@@ -80,12 +66,12 @@ func (ctrl *AuthController) UserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
-	user, err := ctrl.AuthService.GetUserByID(userID)
+	user, err := ctrl.AuthService.GetProfile(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(user)
+	return c.Status(fiber.StatusOK).JSON(user.Private())
 }
 
 func (ctrl *AuthController) UserUpdate(c *fiber.Ctx) error {
@@ -104,24 +90,31 @@ func (ctrl *AuthController) UserUpdate(c *fiber.Ctx) error {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 		Location string `json:"location"`
-		Role     string `json:"role"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	user, err := ctrl.AuthService.UpdateUser(userIDStr, models.Users{
+	updated, err := ctrl.AuthService.UpdateUser(userIDStr, models.Users{
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: req.Password,
 		Location:     req.Location,
 	})
+	if err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AuthService.GetProfile(updated.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(user)
+	return c.Status(fiber.StatusOK).JSON(user.Private())
 }
 
 func (ctrl *AuthController) LoginHandler(c *fiber.Ctx) error {
@@ -134,17 +127,188 @@ func (ctrl *AuthController) LoginHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	accessToken, refreshToken, err := ctrl.AuthService.Login(req.Email, req.Password)
+	result, err := ctrl.AuthService.Login(req.Email, req.Password, clientInfo(c))
 	if err != nil {
+		var locked *services.LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
+		var suspended *services.SuspendedError
+		if errors.As(err, &suspended) {
+			return suspendedResponse(c, suspended)
+		}
+		if errors.Is(err, services.ErrEmailNotConfirmed) || errors.Is(err, services.ErrPasswordResetNeeded) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	return loginResponse(c, result)
+}
+
+// clientInfo décrit l'appareil à l'origine de la requête ; le client peut nommer l'appareil via l'en-tête X-Device-Name
+func clientInfo(c *fiber.Ctx) services.ClientInfo {
+	return services.ClientInfo{
+		IP:         c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		DeviceName: c.Get("X-Device-Name"),
+	}
+}
+
+// loginResponse renvoie les tokens, ou le challengeToken si un second facteur est attendu
+func loginResponse(c *fiber.Ctx, result services.LoginResult) error {
+	// Double authentification : le client doit appeler /login/2fa avec le challengeToken et un code
+	if result.ChallengeToken != "" {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"mfaRequired":    true,
+			"challengeToken": result.ChallengeToken,
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
+		"accessToken":  result.AccessToken,
+		"refreshToken": result.RefreshToken,
+	})
+}
+
+// LoginTwoFactorHandler termine la connexion d'un utilisateur ayant activé la double authentification
+func (ctrl *AuthController) LoginTwoFactorHandler(c *fiber.Ctx) error {
+	var req struct {
+		ChallengeToken string `json:"challengeToken" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := ctrl.AuthService.VerifyTwoFactorLogin(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		var locked *services.LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
+		var suspended *services.SuspendedError
+		if errors.As(err, &suspended) {
+			return suspendedResponse(c, suspended)
+		}
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"accessToken":  result.AccessToken,
+		"refreshToken": result.RefreshToken,
+	})
+}
+
+// SetupTwoFactorHandler génère un secret TOTP et l'URI à afficher en QR code
+func (ctrl *AuthController) SetupTwoFactorHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	setup, err := ctrl.AuthService.SetupTwoFactor(userID)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(setup)
+}
+
+// EnableTwoFactorHandler active la double authentification et retourne les codes de secours
+func (ctrl *AuthController) EnableTwoFactorHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	codes, err := ctrl.AuthService.EnableTwoFactor(userID, req.Code)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"recoveryCodes": codes})
+}
+
+// DisableTwoFactorHandler désactive la double authentification
+func (ctrl *AuthController) DisableTwoFactorHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := ctrl.AuthService.DisableTwoFactor(userID, req.Password, req.Code); err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodesHandler remplace les codes de secours de l'utilisateur
+func (ctrl *AuthController) RegenerateRecoveryCodesHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	codes, err := ctrl.AuthService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"recoveryCodes": codes})
+}
+
+// lockedResponse répond 429 avec l'en-tête Retry-After lorsque la connexion est temporairement bloquée
+func lockedResponse(c *fiber.Ctx, locked *services.LockedError) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": locked.Error()})
+}
+
+// suspendedResponse répond 403 en indiquant le motif et la fin de la suspension (absente pour un bannissement)
+func suspendedResponse(c *fiber.Ctx, suspended *services.SuspendedError) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":           suspended.Error(),
+		"reason":          suspended.Reason,
+		"suspended_until": suspended.Until,
 	})
 }
 
+// twoFactorErrorStatus associe les erreurs de double authentification à un code HTTP
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrInvalidChallenge), errors.Is(err, services.ErrInvalidCredentials):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled), errors.Is(err, services.ErrTwoFactorNotEnabled), errors.Is(err, services.ErrTwoFactorNotSetUp):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
 func (ctrl *AuthController) RefreshHandler(c *fiber.Ctx) error {
 	var req struct {
 		RefreshToken string `json:"refreshToken" binding:"required"`
@@ -154,7 +318,7 @@ func (ctrl *AuthController) RefreshHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	newAccessToken, newRefreshToken, err := ctrl.AuthService.Refresh(req.RefreshToken)
+	newAccessToken, newRefreshToken, err := ctrl.AuthService.Refresh(req.RefreshToken, c.IP())
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -165,78 +329,265 @@ func (ctrl *AuthController) RefreshHandler(c *fiber.Ctx) error {
 	})
 }
 
-// GoogleLogin redirige l'utilisateur vers la page de connexion Google
-// @Summary Rediriger l'utilisateur vers la page de connexion Google
-// @Description Rediriger l'utilisateur vers la page de connexion Google
+// LogoutHandler révoque le refresh token présenté ainsi que toute sa famille
+func (ctrl *AuthController) LogoutHandler(c *fiber.Ctx) error {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing refresh token"})
+	}
+
+	if err := ctrl.AuthService.Logout(req.RefreshToken); err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out"})
+}
+
+// GetSessionsHandler liste les appareils connectés au compte de l'utilisateur
+// @Summary Lister les sessions actives
+// @Description Retourne les appareils connectés (libellé, user agent, IP, dates de création et de dernière activité)
 // @Tags Auth
 // @Produce json
-// @Success 302 {string} string
-// @Router /api/auth/google [get]
-// GoogleLogin redirige l'utilisateur vers la page de connexion Google
-// GoogleLogin redirige l'utilisateur vers la page de connexion Google
-func (ctrl *AuthController) GoogleLogin(c *fiber.Ctx) error {
-	url := ctrl.AuthService.GoogleOauthConfig.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	return c.Redirect(url)
+// @Security BearerAuth
+// @Success 200 {array} models.Session
+// @Router /api/sessions [get]
+func (ctrl *AuthController) GetSessionsHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+
+	sessions, err := ctrl.AuthService.GetSessions(userID, sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
 }
 
-// GoogleCallback gère le callback de Google après l'authentification
-func (ctrl *AuthController) GoogleCallback(c *fiber.Ctx) error {
+// RevokeSessionHandler déconnecte un appareil donné
+func (ctrl *AuthController) RevokeSessionHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := ctrl.AuthService.RevokeSession(userID, c.Params("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session revoked"})
+}
+
+// RevokeOtherSessionsHandler déconnecte tous les appareils sauf celui de l'appelant
+// (pour se déconnecter partout, y compris de l'appareil courant, voir LogoutAllHandler)
+func (ctrl *AuthController) RevokeOtherSessionsHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+
+	if err := ctrl.AuthService.RevokeOtherSessions(userID, sessionID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Other sessions revoked"})
+}
+
+// LogoutAllHandler déconnecte l'utilisateur de tous ses appareils
+func (ctrl *AuthController) LogoutAllHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := ctrl.AuthService.LogoutAll(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out from all devices"})
+}
+
+// ForgotPasswordHandler envoie un lien de réinitialisation si l'adresse est inscrite.
+// La réponse est identique dans tous les cas pour ne pas révéler les comptes existants.
+func (ctrl *AuthController) ForgotPasswordHandler(c *fiber.Ctx) error {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing email"})
+	}
+
+	if err := ctrl.AuthService.RequestPasswordReset(req.Email); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process request"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "If this email is registered, a reset link has been sent"})
+}
+
+// ResetPasswordHandler définit un nouveau mot de passe à partir du jeton reçu par email
+func (ctrl *AuthController) ResetPasswordHandler(c *fiber.Ctx) error {
 	var req struct {
-		IDToken string `json:"idToken"`
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if req.IDToken == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing token"})
+	if err := ctrl.AuthService.ResetPassword(req.Token, req.Password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidResetToken), errors.Is(err, services.ErrWeakPassword):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
 	}
 
-	token, err := ctrl.AuthService.GoogleOauthConfig.TokenSource(context.Background(), &oauth2.Token{
-		AccessToken: req.IDToken,
-	}).Token()
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password updated, please log in again"})
+}
+
+// JWKSHandler publie les clés publiques de vérification des JWT (JSON Web Key Set)
+// @Summary Clés publiques de vérification des tokens
+// @Description Retourne le JWKS permettant aux autres services de vérifier les tokens émis par l'API
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/jwks.json [get]
+func (ctrl *AuthController) JWKSHandler(c *fiber.Ctx) error {
+	jwks, err := middlewares.JWKS()
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Keys unavailable"})
 	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(jwks)
+}
+
+// OAuthProvidersHandler liste les fournisseurs d'identité activés
+func (ctrl *AuthController) OAuthProvidersHandler(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"providers": ctrl.OAuthService.ProviderNames()})
+}
 
-	client := ctrl.AuthService.GoogleOauthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+// OAuthLoginHandler redirige l'utilisateur vers la page de connexion du fournisseur
+// @Summary Rediriger l'utilisateur vers la page de connexion d'un fournisseur d'identité
+// @Description Rediriger l'utilisateur vers le fournisseur (google, apple, github, keycloak...) avec state et PKCE
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Fournisseur"
+// @Success 302 {string} string
+// @Router /api/auth/{provider} [get]
+func (ctrl *AuthController) OAuthLoginHandler(c *fiber.Ctx) error {
+	url, err := ctrl.OAuthService.BeginLogin(c.UserContext(), c.Params("provider"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get user info"})
+		return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
-	defer resp.Body.Close()
+	return c.Redirect(url)
+}
 
-	var userInfo struct {
-		Email string `json:"email"`
+// OAuthCallbackHandler gère le retour du fournisseur : vérification du state, échange du code et de l'identité.
+// Le callback est reçu en GET, ou en POST pour les fournisseurs utilisant response_mode=form_post (Apple).
+func (ctrl *AuthController) OAuthCallbackHandler(c *fiber.Ctx) error {
+	param := c.Query
+	if c.Method() == fiber.MethodPost {
+		param = c.FormValue
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode user info"})
+	if errParam := param("error"); errParam != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errParam})
 	}
 
-	// Rechercher l'utilisateur dans la base de données
-	user, err := ctrl.AuthService.GetUserByEmail(userInfo.Email)
+	result, err := ctrl.OAuthService.Complete(c.UserContext(), c.Params("provider"), param("state"), param("code"), clientInfo(c))
 	if err != nil {
-		// Si l'utilisateur n'existe pas, créez un nouvel utilisateur
-		user, err = ctrl.AuthService.RegisterUser(models.Users{
-			Email: userInfo.Email,
-		})
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to register user"})
+		var suspended *services.SuspendedError
+		if errors.As(err, &suspended) {
+			return suspendedResponse(c, suspended)
 		}
+		return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if result.Linked {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Identity linked"})
 	}
+	return loginResponse(c, result.Login)
+}
 
-	// Générer un token JWT pour l'utilisateur
-	userID, err := ulid.Parse(user.ID)
+// GetIdentitiesHandler liste les fournisseurs reliés au compte de l'utilisateur connecté
+func (ctrl *AuthController) GetIdentitiesHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	identities, err := ctrl.OAuthService.GetIdentities(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse user ID"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(identities)
+}
+
+// LinkIdentityHandler retourne l'URL d'autorisation permettant de relier un fournisseur au compte.
+// Le client doit y rediriger l'utilisateur ; la liaison est effectuée au retour sur le callback.
+func (ctrl *AuthController) LinkIdentityHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
-	accessToken, err := middlewares.GenerateToken(userID)
+
+	url, err := ctrl.OAuthService.BeginLink(c.UserContext(), c.Params("provider"), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate access token"})
+		return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"url": url})
+}
+
+// UnlinkIdentityHandler détache un fournisseur du compte
+func (ctrl *AuthController) UnlinkIdentityHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := ctrl.OAuthService.UnlinkIdentity(userID, c.Params("provider")); err != nil {
+		return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"accessToken": accessToken})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Identity unlinked"})
+}
+
+// oauthErrorStatus associe les erreurs de connexion externe à un code HTTP
+func oauthErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider), errors.Is(err, services.ErrIdentityNotLinked):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrInvalidOAuthState):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidIDToken), errors.Is(err, services.ErrEmailNotVerified):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrIdentityInUse), errors.Is(err, services.ErrLastLoginMethod):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusBadGateway
+	}
 }
 
 func (ctrl *AuthController) ConfirmEmailHandler(c *fiber.Ctx) error {
@@ -245,23 +596,49 @@ func (ctrl *AuthController) ConfirmEmailHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token manquant"})
 	}
 
-	var user models.Users
-	if err := ctrl.AuthService.DB.Where("confirmation_token = ?", token).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Utilisateur non trouvé"})
+	if err := ctrl.AuthService.ConfirmEmail(token); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidConfirmation):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Lien de confirmation invalide ou expiré"})
+		case errors.Is(err, services.ErrEmailTaken):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Cette adresse email est déjà utilisée"})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Erreur lors de la confirmation de l'email"})
+		}
 	}
 
-	user.IsConfirmed = true
-	user.ConfirmationToken = ""
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Email confirmé avec succès"})
+}
 
-	if err := ctrl.AuthService.DB.Save(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Erreur lors de la confirmation de l'email"})
+// ResendConfirmationHandler renvoie l'email de confirmation d'un compte non confirmé.
+// La réponse ne révèle pas si l'adresse est inscrite.
+func (ctrl *AuthController) ResendConfirmationHandler(c *fiber.Ctx) error {
+	var req struct {
+		Email string `json:"email" binding:"required"`
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Email confirmé avec succès"})
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing email"})
+	}
+
+	if err := ctrl.AuthService.ResendConfirmation(req.Email); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process request"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "If this account exists and is not confirmed, a new confirmation email has been sent"})
 }
 
+// GetUsersHandler liste les utilisateurs, chaque profil étant filtré selon ses réglages de visibilité
 func (ctrl *AuthController) GetUsersHandler(c *fiber.Ctx) error {
-	users, err := ctrl.AuthService.GetAllUsers()
+	viewerID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	users, err := ctrl.AuthService.GetPublicUsers(viewerID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -269,27 +646,62 @@ func (ctrl *AuthController) GetUsersHandler(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(users)
 }
 
+// GetPublicUserInfoHandler retourne le profil public d'un utilisateur tel que l'appelant peut le voir
 func (ctrl *AuthController) GetPublicUserInfoHandler(c *fiber.Ctx) error {
-	userID := c.Params("id")
-	publicInfo, err := ctrl.AuthService.GetPublicUserInfoByID(userID)
+	viewerID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	profile, err := ctrl.AuthService.GetPublicProfile(viewerID, c.Params("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(profile)
+}
+
+// GetPrivacySettingsHandler retourne les réglages de visibilité du profil de l'utilisateur connecté
+func (ctrl *AuthController) GetPrivacySettingsHandler(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	user, err := ctrl.AuthService.GetUserByID(userID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	return c.JSON(publicInfo)
+	return c.Status(fiber.StatusOK).JSON(user.Privacy())
 }
 
-// DeleteUserHandler gère la demande de suppression d'un utilisateur
-func (ctrl *AuthController) DeleteUserHandler(c *fiber.Ctx) error {
+// UpdatePrivacySettingsHandler modifie la visibilité (everyone, friends, nobody) de l'email,
+// de la localisation, de la date de naissance et de la bio
+func (ctrl *AuthController) UpdatePrivacySettingsHandler(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(string)
 	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
-	err := ctrl.AuthService.DeleteUserAndRelatedData(userID)
+	var req services.PrivacySettingsUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.AuthService.UpdatePrivacySettings(userID, req)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidVisibility):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Utilisateur supprimé avec succès"})
+	return c.Status(fiber.StatusOK).JSON(user.Privacy())
 }
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type CalendarController struct {
+	CalendarService *services.CalendarService
+}
+
+// NewCalendarController creates a new CalendarController instance
+func NewCalendarController(calendarService *services.CalendarService) *CalendarController {
+	return &CalendarController{
+		CalendarService: calendarService,
+	}
+}
+
+// GetEventICS downloads a single event as an .ics file
+func (cc *CalendarController) GetEventICS(c *fiber.Ctx) error {
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	viewerID, _ := c.Locals("user_id").(string)
+	ics, err := cc.CalendarService.EventICS(eventID, viewerID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"event-%d.ics\"", eventID))
+	return c.SendString(ics)
+}
+
+// CreateFeedToken generates (or rotates) the calendar feed token of the current user
+func (cc *CalendarController) CreateFeedToken(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	token, err := cc.CalendarService.RotateFeedToken(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create feed token"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token": token,
+		"url":   c.BaseURL() + "/feeds/calendar/" + token + ".ics",
+	})
+}
+
+// RevokeFeedToken disables the calendar feed of the current user
+func (cc *CalendarController) RevokeFeedToken(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := cc.CalendarService.RevokeFeedToken(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke feed token"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Feed token revoked successfully"})
+}
+
+// GetUserFeed serves the subscribable calendar feed authenticated by its feed token
+func (cc *CalendarController) GetUserFeed(c *fiber.Ctx) error {
+	ics, err := cc.CalendarService.UserFeedICS(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Feed not found"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.SendString(ics)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type DataExportController struct {
+	DataExportService *services.DataExportService
+}
+
+// NewDataExportController crée une nouvelle instance de DataExportController
+func NewDataExportController(dataExportService *services.DataExportService) *DataExportController {
+	return &DataExportController{
+		DataExportService: dataExportService,
+	}
+}
+
+// RequestDataExport lance la génération de l'archive des données personnelles de l'utilisateur
+// @Summary Demander l'export de ses données (RGPD)
+// @Description L'archive (fichiers JSON et images) est générée en tâche de fond ; un lien de téléchargement est envoyé par email
+// @Tags Data export
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.DataExport
+// @Router /api/data_exports [post]
+func (ctrl *DataExportController) RequestDataExport(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	export, err := ctrl.DataExportService.RequestExport(userID)
+	if err != nil {
+		return c.Status(dataExportErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(export)
+}
+
+// GetDataExports liste les exports de l'utilisateur connecté
+func (ctrl *DataExportController) GetDataExports(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	exports, err := ctrl.DataExportService.GetExports(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(exports)
+}
+
+// GetDataExport retourne l'état d'un export et, s'il est prêt, un nouveau lien de téléchargement signé
+func (ctrl *DataExportController) GetDataExport(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	export, err := ctrl.DataExportService.GetExport(userID, c.Params("id"))
+	if err != nil {
+		return c.Status(dataExportErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := fiber.Map{"export": export}
+	if export.Status == models.ExportCompleted {
+		if link, expires, err := ctrl.DataExportService.DownloadURL(export); err == nil {
+			response["download_url"] = link
+			response["download_url_expires_at"] = expires
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// DownloadDataExport sert l'archive d'un export à partir d'un lien signé (sans JWT, le lien faisant foi)
+func (ctrl *DataExportController) DownloadDataExport(c *fiber.Ctx) error {
+	path, err := ctrl.DataExportService.OpenDownload(c.Params("id"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		return c.Status(dataExportErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Download(path, "freemusic-data-"+c.Params("id")+".zip")
+}
+
+// dataExportErrorStatus associe les erreurs du service d'export à un code HTTP
+func dataExportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrExportNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrExportInProgress):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrExportTooSoon):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, services.ErrExportNotReady):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrInvalidDownloadLink):
+		return fiber.StatusForbidden
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
@@ -57,8 +59,15 @@ func (ec *EventController) CreateEvent(c *fiber.Ctx) error {
 	event.Latitude = lat
 	event.Longitude = lng
 
+	if organizerID, ok := c.Locals("user_id").(string); ok {
+		event.OrganizerID = organizerID
+	}
+
 	// Create the event
 	err = ec.EventService.CreateEvent(&event, 1)
+	if errors.Is(err, services.ErrInvalidEvent) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create event"})
 	}
@@ -66,9 +75,10 @@ func (ec *EventController) CreateEvent(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"event": event})
 }
 
-// GetAllEvents retrieves all events
+// GetAllEvents retrieves all events visible to the current user (?q= searches titles)
 func (ec *EventController) GetAllEvents(c *fiber.Ctx) error {
-	events, err := ec.EventService.GetAllEvents()
+	viewerID, _ := c.Locals("user_id").(string)
+	events, err := ec.EventService.GetVisibleEvents(viewerID, c.Query("q"))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch events"})
 	}
@@ -85,7 +95,8 @@ func (ec *EventController) GetEventByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
 	}
 
-	event, err := ec.EventService.GetEventByID(eventIDInt)
+	viewerID, _ := c.Locals("user_id").(string)
+	event, err := ec.EventService.GetVisibleEventByID(eventIDInt, viewerID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
 	}
@@ -94,7 +105,12 @@ func (ec *EventController) GetEventByID(c *fiber.Ctx) error {
 
 // UpdateEvent updates an existing event
 func (ec *EventController) UpdateEvent(c *fiber.Ctx) error {
-	eventID := c.Params("event_id")
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID := c.Params("id")
 	var req models.Event
 
 	// Parse input data
@@ -102,9 +118,9 @@ func (ec *EventController) UpdateEvent(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
 	}
 
-	updatedEvent, err := ec.EventService.UpdateEvent(eventID, &req)
+	updatedEvent, err := ec.EventService.UpdateEvent(eventID, userID, &req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error updating event"})
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(updatedEvent)
 }
@@ -125,6 +141,391 @@ func (ec *EventController) DeleteEvent(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Event deleted successfully"})
 }
 
+// GetOccurrences lists the occurrences of all events within a window (?from=&to=, RFC 3339)
+func (ec *EventController) GetOccurrences(c *fiber.Ctx) error {
+	from, to, err := parseOccurrenceWindow(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	viewerID, _ := c.Locals("user_id").(string)
+	occurrences, err := ec.EventService.GetAllOccurrences(viewerID, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch occurrences"})
+	}
+	return c.JSON(occurrences)
+}
+
+// GetEventOccurrences lists the occurrences of one event within a window (?from=&to=, RFC 3339)
+func (ec *EventController) GetEventOccurrences(c *fiber.Ctx) error {
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	from, to, err := parseOccurrenceWindow(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	viewerID, _ := c.Locals("user_id").(string)
+	occurrences, err := ec.EventService.GetOccurrences(eventID, viewerID, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(occurrences)
+}
+
+// CancelOccurrence cancels a single occurrence of a recurring event of the current user
+func (ec *EventController) CancelOccurrence(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Occurrence time.Time `json:"occurrence"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Occurrence.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid occurrence"})
+	}
+
+	if err := ec.EventService.CancelOccurrence(eventID, userID, req.Occurrence); err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Occurrence cancelled successfully"})
+}
+
+// UpdateOccurrence edits one occurrence, this and the following occurrences, or the whole series
+// of an event of the current user
+func (ec *EventController) UpdateOccurrence(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Occurrence time.Time    `json:"occurrence"`
+		Scope      string       `json:"scope"` // this, following or all
+		Changes    models.Event `json:"changes"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Occurrence.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+	}
+	if req.Scope == "" {
+		req.Scope = services.ScopeThis
+	}
+
+	event, err := ec.EventService.UpdateOccurrence(eventID, userID, req.Occurrence, req.Scope, &req.Changes)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// RSVPEvent records the participation of the current user to an event
+func (ec *EventController) RSVPEvent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Status models.RSVPStatus `json:"status"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+	}
+	if req.Status == "" {
+		req.Status = models.RSVPGoing
+	}
+
+	rsvp, err := ec.EventService.RSVPEvent(eventID, userID, req.Status)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rsvp)
+}
+
+// CancelRSVP removes the participation of the current user to an event
+func (ec *EventController) CancelRSVP(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	if err := ec.EventService.CancelRSVP(eventID, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error cancelling RSVP"})
+	}
+	return c.JSON(fiber.Map{"message": "RSVP cancelled successfully"})
+}
+
+// GetMyEvents lists every event organized by the current user, whatever its publication state
+func (ec *EventController) GetMyEvents(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	events, err := ec.EventService.GetEventsByOrganizerID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch events"})
+	}
+	return c.JSON(events)
+}
+
+// PublishEvent publishes a draft event now or at publish_at (RFC 3339), possibly through admin review
+func (ec *EventController) PublishEvent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+		}
+	}
+
+	event, err := ec.EventService.PublishEvent(eventID, userID, req.PublishAt)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// UnpublishEvent moves an event back to draft
+func (ec *EventController) UnpublishEvent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	event, err := ec.EventService.UnpublishEvent(eventID, userID)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// SetVisibility changes the visibility of an event (public, unlisted or friends)
+func (ec *EventController) SetVisibility(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Visibility models.Visibility `json:"visibility"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+	}
+
+	event, err := ec.EventService.SetVisibility(eventID, userID, req.Visibility)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// GetReviewQueue lists the events awaiting admin review
+func (ec *EventController) GetReviewQueue(c *fiber.Ctx) error {
+	events, err := ec.EventService.GetReviewQueue()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch events"})
+	}
+	return c.JSON(events)
+}
+
+// ApproveEvent publishes an event from the review queue
+func (ec *EventController) ApproveEvent(c *fiber.Ctx) error {
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	event, err := ec.EventService.ApproveEvent(eventID)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// RejectEvent sends an event from the review queue back to its organizer with a reason
+func (ec *EventController) RejectEvent(c *fiber.Ctx) error {
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A reason is required"})
+	}
+
+	event, err := ec.EventService.RejectEvent(eventID, req.Reason)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// CancelEvent cancels an event, refunds paid tickets and notifies attendees
+func (ec *EventController) CancelEvent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+	}
+
+	event, err := ec.EventService.CancelEvent(eventID, userID, req.Reason)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// RescheduleEvent moves an event to a new date and notifies attendees
+func (ec *EventController) RescheduleEvent(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	var req struct {
+		StartTime time.Time  `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+		Reason    string     `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid data"})
+	}
+
+	event, err := ec.EventService.RescheduleEvent(eventID, userID, req.StartTime, req.EndTime, req.Reason)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(event)
+}
+
+// GetEventHistory returns the public history (cancellation, rescheduling) of an event
+func (ec *EventController) GetEventHistory(c *fiber.Ctx) error {
+	eventID, err := strconv.Atoi(c.Params("event_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid event ID"})
+	}
+
+	viewerID, _ := c.Locals("user_id").(string)
+	history, err := ec.EventService.GetEventHistory(eventID, viewerID)
+	if err != nil {
+		return c.Status(eventErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(history)
+}
+
+// eventErrorStatus maps EventService errors to HTTP status codes
+func eventErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrEventNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrNotOrganizer):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrEventCancelled):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusBadRequest
+	}
+}
+
+// maxOccurrenceWindow caps how far occurrences are expanded in a single request
+const maxOccurrenceWindow = 366 * 24 * time.Hour
+
+// parseOccurrenceWindow reads the from/to query parameters, defaulting to the next 30 days
+func parseOccurrenceWindow(c *fiber.Ctx) (time.Time, time.Time, error) {
+	from := time.Now()
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %s", v)
+		}
+		from = t
+	}
+
+	to := from.AddDate(0, 0, 30)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %s", v)
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxOccurrenceWindow {
+		return time.Time{}, time.Time{}, fmt.Errorf("window cannot exceed 366 days")
+	}
+	return from, to, nil
+}
+
 // getCoordinates fetches coordinates for a given address using Google Geocoding API
 func getCoordinates(address, apiKey string) (float64, float64, error) {
 	address = url.QueryEscape(address)
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type EventImportController struct {
+	EventImportService *services.EventImportService
+}
+
+// NewEventImportController creates a new EventImportController instance
+func NewEventImportController(eventImportService *services.EventImportService) *EventImportController {
+	return &EventImportController{
+		EventImportService: eventImportService,
+	}
+}
+
+// ImportEvents starts a background import of events from a CSV or JSON payload.
+//
+// The payload is either the raw request body or a multipart "file" field. The format comes
+// from ?format=csv|json, or is guessed from the content type or file extension.
+// With ?dry_run=true rows are only validated.
+func (ic *EventImportController) ImportEvents(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	data := c.Body()
+	format := strings.ToLower(c.Query("format"))
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot read uploaded file"})
+		}
+		defer f.Close()
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot read uploaded file"})
+		}
+		data = buf
+		if format == "" {
+			format = guessImportFormat(file.Filename)
+		}
+	}
+	if format == "" {
+		format = guessImportFormat(c.Get(fiber.HeaderContentType))
+	}
+
+	job, err := ic.EventImportService.StartImport(userID, format, data, c.QueryBool("dry_run"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetImportJob returns the progress and per-row errors of an import job
+func (ic *EventImportController) GetImportJob(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	job, err := ic.EventImportService.GetImportJob(c.Params("job_id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Import job not found"})
+	}
+	return c.JSON(job)
+}
+
+// ExportEvents downloads the events of the current organizer as CSV or JSON (?format=)
+func (ic *EventImportController) ExportEvents(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	format := strings.ToLower(c.Query("format", services.FormatJSON))
+	data, err := ic.EventImportService.ExportEvents(userID, format)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if format == services.FormatCSV {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"events.%s\"", format))
+	return c.Send(data)
+}
+
+func guessImportFormat(value string) string {
+	value = strings.ToLower(value)
+	switch {
+	case strings.Contains(value, "csv"):
+		return services.FormatCSV
+	case strings.Contains(value, "json"):
+		return services.FormatJSON
+	default:
+		return ""
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -32,6 +33,13 @@ func (cc *FriendChatController) SendMessage(c *fiber.Ctx) error {
 		})
 	}
 
+	// Le message est toujours envoyé au nom de l'utilisateur connecté (sender_id est facultatif)
+	senderID, status, err := ownUserID(c, request.SenderID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	request.SenderID = senderID
+
 	// Vérifiez que sender_id et receiver_id ne sont pas vides
 	if request.SenderID == "" || request.ReceiverID == "" {
 		log.Printf("SenderID or ReceiverID is empty")
@@ -59,6 +67,11 @@ func (cc *FriendChatController) SendMessage(c *fiber.Ctx) error {
 
 	if err := cc.FriendChatService.SendMessage(request.SenderID, request.ReceiverID, request.Content); err != nil {
 		log.Printf("Error sending message: %v", err)
+		if errors.Is(err, services.ErrUserBlocked) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -69,10 +82,32 @@ func (cc *FriendChatController) SendMessage(c *fiber.Ctx) error {
 	})
 }
 
+// GetMessages retourne la conversation entre deux utilisateurs ; l'utilisateur connecté doit en faire partie
 func (cc *FriendChatController) GetMessages(c *fiber.Ctx) error {
+	callerID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
 	senderID := c.Params("senderID")
 	receiverID := c.Params("receiverID")
+	if senderID != callerID && receiverID != callerID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errNotOwner.Error()})
+	}
+
+	return cc.conversation(c, senderID, receiverID)
+}
+
+// GetMyMessages retourne la conversation de l'utilisateur connecté avec un ami
+func (cc *FriendChatController) GetMyMessages(c *fiber.Ctx) error {
+	callerID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	return cc.conversation(c, callerID, c.Params("friendID"))
+}
 
+func (cc *FriendChatController) conversation(c *fiber.Ctx, senderID, receiverID string) error {
 	areFriends, err := cc.FriendService.AreFriends(senderID, receiverID)
 	if err != nil || !areFriends {
 		log.Printf("Users are not friends or error occurred: %v", err)
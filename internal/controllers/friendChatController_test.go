@@ -0,0 +1,48 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oklog/ulid/v2"
+)
+
+func TestMessageRoutesRejectOtherUsers(t *testing.T) {
+	app, fake, token, _ := testApp(t)
+	other, friend := ulid.Make().String(), ulid.Make().String()
+
+	if status := do(t, app, http.MethodGet, "/api/message/messages/"+other+"/"+friend, token, ""); status != fiber.StatusForbidden {
+		t.Errorf("conversation between two other users: status = %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	body := `{"sender_id":"` + other + `","receiver_id":"` + friend + `","content":"hello"}`
+	if status := do(t, app, http.MethodPost, "/api/message/send", token, body); status != fiber.StatusForbidden {
+		t.Errorf("message sent on behalf of another user: status = %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	if queries := fake.Queries(); len(queries) != 0 {
+		t.Errorf("forbidden requests reached the database: %v", queries)
+	}
+}
+
+func TestMessageRoutesRequireFriendship(t *testing.T) {
+	app, _, token, userID := testApp(t)
+	stranger := ulid.Make().String()
+
+	paths := []string{
+		"/api/message/me/" + stranger,
+		"/api/message/messages/" + userID + "/" + stranger,
+		"/api/message/messages/" + stranger + "/" + userID,
+	}
+	for _, path := range paths {
+		if status := do(t, app, http.MethodGet, path, token, ""); status != fiber.StatusForbidden {
+			t.Errorf("GET %s: status = %d, want %d", path, status, fiber.StatusForbidden)
+		}
+	}
+
+	body := `{"receiver_id":"` + stranger + `","content":"hello"}`
+	if status := do(t, app, http.MethodPost, "/api/message/send", token, body); status != fiber.StatusForbidden {
+		t.Errorf("message to a non-friend: status = %d, want %d", status, fiber.StatusForbidden)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,6 +20,21 @@ func NewFriendController(friendService *services.FriendService, notificationServ
 	}
 }
 
+var errNotOwner = errors.New("you can only access your own friends, requests and messages")
+
+// ownUserID vérifie qu'un identifiant fourni par le client (URL ou corps) désigne l'utilisateur du jeton
+// et retourne ce dernier. Un identifiant vide ou "me" désigne l'utilisateur connecté.
+func ownUserID(c *fiber.Ctx, userID string) (string, int, error) {
+	callerID, ok := c.Locals("user_id").(string)
+	if !ok || callerID == "" {
+		return "", fiber.StatusUnauthorized, errors.New("Invalid user ID")
+	}
+	if userID != "" && userID != "me" && userID != callerID {
+		return "", fiber.StatusForbidden, errNotOwner
+	}
+	return callerID, 0, nil
+}
+
 func (fc *FriendController) SendFriendRequest(c *fiber.Ctx) error {
 	var request struct {
 		SenderId   string `json:"sender_id"`
@@ -31,6 +47,13 @@ func (fc *FriendController) SendFriendRequest(c *fiber.Ctx) error {
 		})
 	}
 
+	// La demande est toujours envoyée au nom de l'utilisateur connecté (sender_id est facultatif)
+	senderID, status, err := ownUserID(c, request.SenderId)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	request.SenderId = senderID
+
 	// Vérifiez que sender_id et receiver_id ne sont pas vides
 	if request.SenderId == "" || request.ReceiverId == "" {
 		log.Printf("SenderId or ReceiverId is empty")
@@ -49,7 +72,7 @@ func (fc *FriendController) SendFriendRequest(c *fiber.Ctx) error {
 
 	if err := fc.FriendService.SendFriendRequest(request.SenderId, request.ReceiverId); err != nil {
 		log.Printf("Error sending friend request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -70,8 +93,14 @@ func (fc *FriendController) AcceptFriendRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := fc.FriendService.AcceptFriendRequest(request.SenderId, request.ReceiverId); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	// Seul le destinataire peut répondre à une demande (receiver_id est facultatif)
+	receiverID, status, err := ownUserID(c, request.ReceiverId)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := fc.FriendService.AcceptFriendRequest(request.SenderId, receiverID); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -92,8 +121,14 @@ func (fc *FriendController) DeclineFriendRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := fc.FriendService.DeclineFriendRequest(request.SenderId, request.ReceiverId); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	// Seul le destinataire peut répondre à une demande (receiver_id est facultatif)
+	receiverID, status, err := ownUserID(c, request.ReceiverId)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := fc.FriendService.DeclineFriendRequest(request.SenderId, receiverID); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -103,9 +138,83 @@ func (fc *FriendController) DeclineFriendRequest(c *fiber.Ctx) error {
 	})
 }
 
+// CancelFriendRequest retire une demande d'ami envoyée par l'utilisateur connecté et encore en attente
+func (fc *FriendController) CancelFriendRequest(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var request struct {
+		ReceiverId string `json:"receiver_id"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot parse JSON",
+		})
+	}
+
+	if err := fc.FriendService.CancelFriendRequest(userID, request.ReceiverId); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "friend request cancelled successfully",
+	})
+}
+
+// Unfriend supprime l'amitié entre l'utilisateur connecté et friend_id
+func (fc *FriendController) Unfriend(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var request struct {
+		FriendId string `json:"friend_id"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot parse JSON",
+		})
+	}
+
+	if err := fc.FriendService.Unfriend(userID, request.FriendId); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "friend removed successfully",
+	})
+}
+
+// GetFriendRequests liste les demandes d'amis reçues par l'utilisateur connecté (/friend/me/requests,
+// ou /friend/requests/:userID avec son propre identifiant)
 func (fc *FriendController) GetFriendRequests(c *fiber.Ctx) error {
-	userID := c.Params("userID")
-	friendRequests, err := fc.FriendService.GetFriendRequests(userID)
+	userID, status, err := ownUserID(c, c.Params("userID"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	friendRequests, err := fc.FriendService.GetFriendRequests(userID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(friendRequests)
+}
+
+// GetOutgoingFriendRequests liste les demandes d'amis envoyées par l'utilisateur connecté et encore en attente
+func (fc *FriendController) GetOutgoingFriendRequests(c *fiber.Ctx) error {
+	userID, status, err := ownUserID(c, c.Params("userID"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	friendRequests, err := fc.FriendService.GetOutgoingFriendRequests(userID, userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -114,9 +223,13 @@ func (fc *FriendController) GetFriendRequests(c *fiber.Ctx) error {
 	return c.JSON(friendRequests)
 }
 
+// GetFriends liste les amis de l'utilisateur connecté (/friend/me, ou /friend/:userID avec son propre identifiant)
 func (fc *FriendController) GetFriends(c *fiber.Ctx) error {
-	userID := c.Params("userID")
-	friends, err := fc.FriendService.GetFriends(userID)
+	userID, status, err := ownUserID(c, c.Params("userID"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	friends, err := fc.FriendService.GetFriends(userID, userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -126,8 +239,12 @@ func (fc *FriendController) GetFriends(c *fiber.Ctx) error {
 }
 
 func (fc *FriendController) SearchUsersByUsername(c *fiber.Ctx) error {
+	viewerID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
 	username := c.Query("username")
-	users, err := fc.FriendService.SearchUsersByUsername(username)
+	users, err := fc.FriendService.SearchUsersByUsername(viewerID, username)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -135,3 +252,84 @@ func (fc *FriendController) SearchUsersByUsername(c *fiber.Ctx) error {
 	}
 	return c.JSON(users)
 }
+
+// GetFriendSuggestions propose des utilisateurs à ajouter, classés par amis en commun, événements
+// fréquentés ensemble, artistes favoris partagés et proximité (pagination : limit, offset)
+// @Summary Suggestions d'amis
+// @Tags Friends
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/friend/suggestions [get]
+func (fc *FriendController) GetFriendSuggestions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	suggestions, total, err := fc.FriendService.GetFriendSuggestions(userID, c.QueryInt("limit"), c.QueryInt("offset"))
+	if err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"suggestions": suggestions, "total": total})
+}
+
+// GetBlockedUsers liste les utilisateurs bloqués par l'utilisateur connecté
+func (fc *FriendController) GetBlockedUsers(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	blocked, err := fc.FriendService.GetBlockedUsers(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(blocked)
+}
+
+// BlockUser bloque un utilisateur : l'amitié éventuelle est supprimée, et ni l'un ni l'autre
+// ne peut plus trouver, inviter, écrire ou notifier l'autre
+func (fc *FriendController) BlockUser(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := fc.FriendService.BlockUser(userID, c.Params("userID")); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "user blocked successfully"})
+}
+
+// UnblockUser retire un utilisateur de la liste de blocage (l'amitié supprimée n'est pas rétablie)
+func (fc *FriendController) UnblockUser(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := fc.FriendService.UnblockUser(userID, c.Params("userID")); err != nil {
+		return c.Status(friendErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "user unblocked successfully"})
+}
+
+// friendErrorStatus associe les erreurs des relations entre utilisateurs à un code HTTP
+func friendErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrNotBlocked),
+		errors.Is(err, services.ErrFriendRequestNotFound), errors.Is(err, services.ErrNotFriends):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrCannotBlockSelf):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrUserBlocked):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrAlreadyFriends), errors.Is(err, services.ErrFriendRequestPending):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrFriendRequestCooldown):
+		return fiber.StatusTooManyRequests
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
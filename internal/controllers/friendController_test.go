@@ -0,0 +1,152 @@
+package controllers_test
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/controllers"
+	"github.com/mackenzii/freemusic/internal/dbtest"
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/routes"
+	"github.com/mackenzii/freemusic/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// testApp serves the friend and message routes on an empty database.
+// It returns the database together with an access token for a new user and that user's ID.
+func testApp(t *testing.T) (*fiber.App, *dbtest.DB, string, string) {
+	t.Helper()
+
+	loadTestKeys(t)
+	db, fake := dbtest.Open(t)
+
+	webSocketService := services.NewWebSocketService()
+	authService := services.NewAuthService(db, nil, nil, nil)
+	friendService := services.NewFriendService(db, authService, webSocketService)
+	friendChatService := services.NewFriendChatService(db, webSocketService)
+
+	app := fiber.New()
+	routes.SetupFriendRoutes(app, controllers.NewFriendController(friendService, nil))
+	routes.SetupRoutesFriendMessage(app, controllers.NewfriendChatController(friendChatService, friendService))
+
+	userID := ulid.Make()
+	token, err := middlewares.GenerateToken(userID, ulid.Make().String())
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+	return app, fake, token, userID.String()
+}
+
+// loadTestKeys loads a freshly generated Ed25519 signing key
+func loadTestKeys(t *testing.T) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	t.Setenv("JWT_PRIVATE_KEY_FILE", path)
+	t.Setenv("JWT_PUBLIC_KEY_FILES", "")
+	if err := middlewares.LoadKeys(); err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+}
+
+// do sends a request with the given access token (none if empty) and returns the response status
+func do(t *testing.T, app *fiber.App, method, path, token, body string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestFriendRoutesRejectOtherUsers(t *testing.T) {
+	app, fake, token, _ := testApp(t)
+	other := ulid.Make().String()
+	body := func(fields map[string]string) string {
+		data, _ := json.Marshal(fields)
+		return string(data)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"friends of another user", http.MethodGet, "/api/friend/" + other, ""},
+		{"requests received by another user", http.MethodGet, "/api/friend/requests/" + other, ""},
+		{"requests sent by another user", http.MethodGet, "/api/friend/requests/" + other + "/outgoing", ""},
+		{"send on behalf of another user", http.MethodPost, "/api/friend/send", body(map[string]string{"sender_id": other, "receiver_id": ulid.Make().String()})},
+		{"accept on behalf of another user", http.MethodPost, "/api/friend/accept", body(map[string]string{"sender_id": ulid.Make().String(), "receiver_id": other})},
+		{"decline on behalf of another user", http.MethodPost, "/api/friend/decline", body(map[string]string{"sender_id": ulid.Make().String(), "receiver_id": other})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := do(t, app, tt.method, tt.path, token, tt.body); status != fiber.StatusForbidden {
+				t.Errorf("status = %d, want %d", status, fiber.StatusForbidden)
+			}
+		})
+	}
+
+	if queries := fake.Queries(); len(queries) != 0 {
+		t.Errorf("forbidden requests reached the database: %v", queries)
+	}
+}
+
+func TestFriendRoutesAllowOwnUser(t *testing.T) {
+	app, _, token, userID := testApp(t)
+
+	paths := []string{
+		"/api/friend/me",
+		"/api/friend/me/requests",
+		"/api/friend/me/requests/outgoing",
+		"/api/friend/" + userID,
+		"/api/friend/requests/" + userID,
+		"/api/friend/requests/" + userID + "/outgoing",
+		"/api/friend/search?username=a",
+	}
+	for _, path := range paths {
+		if status := do(t, app, http.MethodGet, path, token, ""); status != fiber.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d", path, status, fiber.StatusOK)
+		}
+	}
+}
+
+func TestFriendRoutesRequireToken(t *testing.T) {
+	app, _, _, userID := testApp(t)
+
+	for _, path := range []string{"/api/friend/me", "/api/friend/" + userID} {
+		if status := do(t, app, http.MethodGet, path, "", ""); status != fiber.StatusUnauthorized {
+			t.Errorf("GET %s: status = %d, want %d", path, status, fiber.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type MusicProfileController struct {
+	MusicProfileService *services.MusicProfileService
+}
+
+// NewMusicProfileController crée une nouvelle instance de MusicProfileController
+func NewMusicProfileController(musicProfileService *services.MusicProfileService) *MusicProfileController {
+	return &MusicProfileController{
+		MusicProfileService: musicProfileService,
+	}
+}
+
+// GetGenres liste les genres musicaux disponibles pour le profil
+func (ctrl *MusicProfileController) GetGenres(c *fiber.Ctx) error {
+	genres, err := ctrl.MusicProfileService.GetGenres()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(genres)
+}
+
+// GetMusicProfile retourne le profil musical de l'utilisateur connecté
+func (ctrl *MusicProfileController) GetMusicProfile(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	profile, err := ctrl.MusicProfileService.GetMusicProfile(userID)
+	if err != nil {
+		return c.Status(musicProfileErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(profile)
+}
+
+// UpdateMusicProfile modifie les genres et artistes favoris, les instruments et les préférences d'écoute
+// @Summary Modifier son profil musical
+// @Description Les champs absents sont conservés ; genre_ids et artist_ids remplacent la liste existante
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.MusicProfile
+// @Router /api/profile/music [put]
+func (ctrl *MusicProfileController) UpdateMusicProfile(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req services.MusicProfileUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	profile, err := ctrl.MusicProfileService.UpdateMusicProfile(userID, req)
+	if err != nil {
+		return c.Status(musicProfileErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(profile)
+}
+
+// musicProfileErrorStatus associe les erreurs du profil musical à un code HTTP
+func musicProfileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrUnknownGenre), errors.Is(err, services.ErrUnknownArtist),
+		errors.Is(err, services.ErrTooManyGenres), errors.Is(err, services.ErrTooManyArtists),
+		errors.Is(err, services.ErrInvalidInstrument), errors.Is(err, services.ErrTooManyInstruments),
+		errors.Is(err, services.ErrListeningPrefsTooLong):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
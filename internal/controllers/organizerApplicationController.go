@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/mackenzii/freemusic/internal/services"
+)
+
+type OrganizerApplicationController struct {
+	OrganizerApplicationService *services.OrganizerApplicationService
+}
+
+// NewOrganizerApplicationController crée une nouvelle instance de OrganizerApplicationController
+func NewOrganizerApplicationController(organizerApplicationService *services.OrganizerApplicationService) *OrganizerApplicationController {
+	return &OrganizerApplicationController{
+		OrganizerApplicationService: organizerApplicationService,
+	}
+}
+
+// Apply dépose une demande de statut d'organisateur
+// @Summary Demander le statut d'organisateur
+// @Description Formulaire multipart : organization, website, description et un à cinq justificatifs (documents, PDF/JPEG/PNG)
+// @Tags Organizer
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} models.OrganizerApplication
+// @Router /api/organizer_applications [post]
+func (ctrl *OrganizerApplicationController) Apply(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Multipart form expected"})
+	}
+
+	application, err := ctrl.OrganizerApplicationService.Apply(userID, services.OrganizerApplicationRequest{
+		Organization: c.FormValue("organization"),
+		Website:      c.FormValue("website"),
+		Description:  c.FormValue("description"),
+	}, form.File["documents"])
+	if err != nil {
+		return c.Status(organizerApplicationErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(application)
+}
+
+// GetMyApplications liste les demandes de l'utilisateur connecté et leur décision
+func (ctrl *OrganizerApplicationController) GetMyApplications(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	applications, err := ctrl.OrganizerApplicationService.GetUserApplications(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(applications)
+}
+
+// GetApplications liste les demandes pour les administrateurs (status : pending par défaut, approved, rejected)
+func (ctrl *OrganizerApplicationController) GetApplications(c *fiber.Ctx) error {
+	applications, err := ctrl.OrganizerApplicationService.GetApplications(models.ApplicationStatus(c.Query("status")))
+	if err != nil {
+		return c.Status(organizerApplicationErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(applications)
+}
+
+// ApproveApplication valide une demande et accorde le badge d'organisateur vérifié
+func (ctrl *OrganizerApplicationController) ApproveApplication(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	note, err := parseReason(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	application, err := ctrl.OrganizerApplicationService.Approve(actor, c.Params("id"), note)
+	if err != nil {
+		return c.Status(organizerApplicationErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(application)
+}
+
+// RejectApplication refuse une demande ; le motif (reason) est obligatoire
+func (ctrl *OrganizerApplicationController) RejectApplication(c *fiber.Ctx) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	reason, err := parseReason(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	application, err := ctrl.OrganizerApplicationService.Reject(actor, c.Params("id"), reason)
+	if err != nil {
+		return c.Status(organizerApplicationErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(application)
+}
+
+// GetDocument sert un justificatif d'une demande aux administrateurs
+func (ctrl *OrganizerApplicationController) GetDocument(c *fiber.Ctx) error {
+	path, err := ctrl.OrganizerApplicationService.DocumentPath(c.Params("id"), c.Params("name"))
+	if err != nil {
+		return c.Status(organizerApplicationErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendFile(path)
+}
+
+// organizerApplicationErrorStatus associe les erreurs des demandes d'organisateur à un code HTTP
+func organizerApplicationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrApplicationNotFound), errors.Is(err, services.ErrDocumentNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, services.ErrOrganizationRequired), errors.Is(err, services.ErrOrganizationTooLong),
+		errors.Is(err, services.ErrDescriptionTooLong), errors.Is(err, services.ErrInvalidWebsite),
+		errors.Is(err, services.ErrDocumentsRequired), errors.Is(err, services.ErrTooManyDocuments),
+		errors.Is(err, services.ErrInvalidDocument), errors.Is(err, services.ErrReasonRequired),
+		errors.Is(err, services.ErrInvalidApplicationStatus):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrCannotModerateSelf):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrApplicationPending), errors.Is(err, services.ErrApplicationReviewed),
+		errors.Is(err, services.ErrAlreadyVerifiedOrganizer):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,106 @@
+// Package dbtest fournit une base de données vide, sans serveur, pour tester les contrôleurs
+// et services qui utilisent gorm : toutes les requêtes réussissent et ne retournent aucune ligne.
+//
+// Les requêtes reçues sont enregistrées, ce qui permet de vérifier qu'une requête refusée
+// n'a jamais atteint la base.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DB est le pilote database/sql de la base vide
+type DB struct {
+	mutex   sync.Mutex
+	queries []string
+}
+
+func (d *DB) record(query string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.queries = append(d.queries, query)
+}
+
+// Queries retourne les requêtes exécutées depuis l'ouverture de la base
+func (d *DB) Queries() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.queries...)
+}
+
+func (d *DB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db: d}, nil }
+func (d *DB) Driver() driver.Driver                        { return d }
+func (d *DB) Open(string) (driver.Conn, error)             { return fakeConn{db: d}, nil }
+
+type fakeConn struct{ db *DB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	return fakeRows{}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+type fakeStmt struct {
+	db    *DB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.db.record(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.db.record(s.query)
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+// Open ouvre une connexion gorm (dialecte PostgreSQL) sur une nouvelle base vide
+func Open(t *testing.T) (*gorm.DB, *DB) {
+	t.Helper()
+
+	fake := &DB{}
+	sqlDB := sql.OpenDB(fake)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open fake database: %v", err)
+	}
+	return db, fake
+}
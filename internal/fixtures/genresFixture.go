@@ -0,0 +1,22 @@
+package fixtures
+
+import (
+	"log"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+// GenerateGenres insère les genres proposés dans les profils musicaux
+func GenerateGenres(db *gorm.DB) error {
+	for _, name := range models.GenreCatalogue {
+		genre := models.Genre{Name: name}
+		if err := db.Where("name = ?", name).FirstOrCreate(&genre).Error; err != nil {
+			log.Printf("Erreur lors de l'insertion du genre %s: %v\n", name, err)
+			return err
+		}
+	}
+	log.Println("Genres fixtures loaded successfully!")
+
+	return nil
+}
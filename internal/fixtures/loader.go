@@ -11,6 +11,7 @@ func LoadFixtures(db *gorm.DB) {
 	log.Println("Loading fixtures...")
 	GenerateUsers(db)      // Charger les utilisateurs
 	GenerateArtists(db)    // Charger les artistes
+	GenerateGenres(db)     // Charger les genres musicaux
 	GenerateCategories(db) // Charger les catégories
 	GenerateEvents(db)     // Charger les événements
 
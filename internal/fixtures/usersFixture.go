@@ -36,7 +36,7 @@ func GenerateUsers(db *gorm.DB) error {
 			Location:     "Paris",
 			Latitude:     48.8566 + float64(i)*0.0001,
 			Longitude:    2.3522 + float64(i)*0.0001,
-			SkillLevel:   "beginner", // Exemple de niveau
+			Instruments:  "guitare,piano", // Exemple d'instruments pratiqués
 			Bio:          "Ceci est un exemple de bio.",
 			FCMToken:     fmt.Sprintf("fcm_token_%03d", i),
 		}
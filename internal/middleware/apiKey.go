@@ -0,0 +1,146 @@
+package middlewares
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+)
+
+// APIKeyPrefix préfixe toutes les clés d'API, ce qui permet de les distinguer d'un JWT
+const APIKeyPrefix = "fmk_"
+
+// ErrInvalidAPIKey est retourné par un APIKeyAuthenticator pour une clé inconnue, révoquée ou expirée
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyPrincipal est l'utilisateur authentifié par une clé d'API, avec les scopes de la clé
+type APIKeyPrincipal struct {
+	KeyID  string
+	UserID string
+	Scopes []models.APIKeyScope
+}
+
+// HasScope indique si la clé dispose du scope donné
+func (p *APIKeyPrincipal) HasScope(scope models.APIKeyScope) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyAuthenticator vérifie une clé d'API (existence, révocation, expiration) et enregistre son utilisation
+type APIKeyAuthenticator func(key, ip string) (*APIKeyPrincipal, error)
+
+var apiKeyAuthenticator APIKeyAuthenticator
+
+// SetAPIKeyAuthenticator enregistre la vérification des clés d'API utilisée par JWTMiddleware
+func SetAPIKeyAuthenticator(authenticator APIKeyAuthenticator) {
+	apiKeyAuthenticator = authenticator
+}
+
+// apiKeyRoute est une route sur laquelle une clé d'API est acceptée
+type apiKeyRoute struct {
+	method   string
+	segments []string
+	scope    models.APIKeyScope
+}
+
+var (
+	apiKeyRoutesMu sync.RWMutex
+	apiKeyRoutes   []apiKeyRoute
+)
+
+// AllowAPIKey autorise les clés d'API ayant le scope donné sur une route (chemin complet, paramètres ":name" acceptés).
+//
+// Les clés d'API sont refusées par défaut : une route non déclarée ici n'accepte que les JWT,
+// si bien qu'une clé divulguée ne permet ni de gérer le compte ni de créer d'autres clés.
+func AllowAPIKey(method, path string, scope models.APIKeyScope) {
+	apiKeyRoutesMu.Lock()
+	defer apiKeyRoutesMu.Unlock()
+	apiKeyRoutes = append(apiKeyRoutes, apiKeyRoute{
+		method:   method,
+		segments: splitPath(path),
+		scope:    scope,
+	})
+}
+
+// apiKeyScopeFor retourne le scope requis pour une requête, en privilégiant la route la plus spécifique
+func apiKeyScopeFor(method, path string) (models.APIKeyScope, bool) {
+	apiKeyRoutesMu.RLock()
+	defer apiKeyRoutesMu.RUnlock()
+
+	segments := splitPath(path)
+	var scope models.APIKeyScope
+	bestParams := -1
+	for _, route := range apiKeyRoutes {
+		if route.method != method || len(route.segments) != len(segments) {
+			continue
+		}
+		params, ok := matchSegments(route.segments, segments)
+		if ok && (bestParams == -1 || params < bestParams) {
+			scope, bestParams = route.scope, params
+		}
+	}
+	return scope, bestParams != -1
+}
+
+func matchSegments(pattern, segments []string) (int, bool) {
+	params := 0
+	for i, p := range pattern {
+		if strings.HasPrefix(p, ":") {
+			if segments[i] == "" {
+				return 0, false
+			}
+			params++
+			continue
+		}
+		if !strings.EqualFold(p, segments[i]) {
+			return 0, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// apiKeyFromRequest retourne la clé d'API présentée (en-tête X-API-Key ou Authorization: Bearer fmk_...)
+func apiKeyFromRequest(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// authenticateAPIKey authentifie une requête par clé d'API, si la route l'autorise pour un scope de la clé.
+// Une requête déjà authentifiée par le JWTMiddleware d'un groupe englobant n'est pas vérifiée une seconde fois.
+func authenticateAPIKey(c *fiber.Ctx, key string) error {
+	if _, ok := c.Locals("api_key_id").(string); ok {
+		return c.Next()
+	}
+
+	scope, allowed := apiKeyScopeFor(c.Method(), c.Path())
+	if !allowed || apiKeyAuthenticator == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys are not accepted on this route"})
+	}
+
+	principal, err := apiKeyAuthenticator(key, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid API key"})
+	}
+	if !principal.HasScope(scope) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API key lacks scope " + string(scope)})
+	}
+
+	c.Locals("user_id", principal.UserID)
+	c.Locals("api_key_id", principal.KeyID)
+	return c.Next()
+}
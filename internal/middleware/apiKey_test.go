@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mackenzii/freemusic/internal/models"
+)
+
+func TestAPIKeyAuthenticatedOnce(t *testing.T) {
+	AllowAPIKey(fiber.MethodGet, "/api/events", models.ScopeEventsRead)
+
+	authentications := 0
+	SetAPIKeyAuthenticator(func(key, ip string) (*APIKeyPrincipal, error) {
+		authentications++
+		return &APIKeyPrincipal{KeyID: "key", UserID: "user", Scopes: []models.APIKeyScope{models.ScopeEventsRead}}, nil
+	})
+	t.Cleanup(func() { SetAPIKeyAuthenticator(nil) })
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/events/", nil)
+	req.Header.Set("X-API-Key", APIKeyPrefix+"secret")
+	resp, err := stackedApp().Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if authentications != 1 {
+		t.Errorf("API key authenticated %d times, want 1", authentications)
+	}
+}
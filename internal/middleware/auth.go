@@ -2,7 +2,6 @@ package middlewares
 
 import (
 	"errors"
-	"os"
 	"strings"
 	"time"
 
@@ -10,88 +9,156 @@ import (
 	"github.com/mackenzii/freemusic/internal/models"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/oklog/ulid/v2"
 )
 
+// Types de token : un refresh token ne peut pas servir de token d'accès, et inversement
+const (
+	TokenTypeAccess       = "access"
+	TokenTypeRefresh      = "refresh"
+	TokenTypeMFAChallenge = "mfa_challenge"
+)
+
 type Claims struct {
-	UserID ulid.ULID   `json:"user_id"`
-	Role   models.Role `json:"role"`
+	UserID    ulid.ULID   `json:"user_id"`
+	Role      models.Role `json:"role"`
+	TokenType string      `json:"token_type"`
+	// SessionID identifie la session (famille de refresh tokens) à l'origine d'un token d'accès
+	SessionID string `json:"sid,omitempty"`
+	// IssuedAtMicro est la date d'émission à la microseconde (iat n'est qu'à la seconde près)
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
 	jwt.StandardClaims
 }
 
-// GenerateToken génère un nouveau token JWT pour un utilisateur donné
+// IssuedBefore indique si le token a été émis avant t.
+// iat_us est utilisé quand il est présent, pour qu'un token émis juste après une révocation
+// (dans la même seconde) reste valide ; les tokens qui n'ont que iat sont refusés jusqu'à la seconde incluse.
+func (c *Claims) IssuedBefore(t time.Time) bool {
+	if c.IssuedAtMicro != 0 {
+		return c.IssuedAtMicro < t.UnixMicro()
+	}
+	return c.IssuedAt <= t.Unix()
+}
+
+// SessionValidator vérifie qu'un token d'accès correspond toujours à une session valide
+// (utilisateur existant, sessions non révoquées...). Il est appelé par JWTMiddleware s'il est défini.
+type SessionValidator func(claims *Claims) error
+
+var sessionValidator SessionValidator
+
+// SetSessionValidator enregistre la vérification de session utilisée par JWTMiddleware
+func SetSessionValidator(validator SessionValidator) {
+	sessionValidator = validator
+}
+
+// GenerateToken génère un nouveau token JWT pour un utilisateur donné, rattaché à la session sessionID
 //
 // Le token est valable pour 24h.
-func GenerateToken(userID ulid.ULID) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	if secretKey == "" {
-		return "", errors.New("SECRET_KEY not found")
-	}
+func GenerateToken(userID ulid.ULID, sessionID string) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		UserID: userID,
+		UserID:        userID,
+		TokenType:     TokenTypeAccess,
+		SessionID:     sessionID,
+		IssuedAtMicro: now.UnixMicro(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
-			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: now.Add(time.Hour * 24).Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return signClaims(&claims)
 }
 
 // ParseToken décode un token JWT et retourne les claims associés.
 //
-// Cette fonction prend un token JWT (tokenString) en entrée et utilise la clé secrète pour le décoder.
+// Cette fonction prend un token JWT (tokenString) en entrée et le vérifie avec la clé désignée par son kid,
+// en imposant l'algorithme associé à cette clé, puis contrôle les claims iss et aud.
 // Si le token est valide, les claims (informations contenues dans le token) sont retournés.
-// En cas d'erreur, elle retourne une erreur appropriée, comme une clé secrète manquante,
+// En cas d'erreur, elle retourne une erreur appropriée, comme des clés non chargées,
 // un token invalide ou un problème lors du parsing.
 func ParseToken(tokenString string) (*Claims, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	if secretKey == "" {
-		return nil, errors.New("SECRET_KEY not found")
+	set, err := currentKeys()
+	if err != nil {
+		return nil, err
 	}
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKeyFunc(set))
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyIssuer(set.issuer, true) || !claims.VerifyAudience(set.audience, true) {
+		return nil, errors.New("invalid token issuer or audience")
 	}
 
-	return nil, errors.New("invalid token")
+	return claims, nil
 }
 
+// RefreshTokenTTL is the lifetime of a refresh token.
+const RefreshTokenTTL = 72 * time.Hour
+
 // GenerateRefreshToken generates a new refresh token for a given user.
-// The token is valid for 72 hours.
-// It requires a valid SECRET_KEY environment variable.
-// Returns the signed token as a string or an error if the secret key is missing.
-func GenerateRefreshToken(userID ulid.ULID) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	if secretKey == "" {
-		return "", errors.New("SECRET_KEY not found")
+// The token is valid for 72 hours and carries tokenID as its jti, so that it can be
+// matched against the server-side record used for rotation and revocation.
+// It requires the signing keys to be loaded (see LoadKeys).
+// Returns the signed token as a string or an error if no signing key is available.
+func GenerateRefreshToken(userID ulid.ULID, tokenID string) (string, error) {
+	claims := Claims{
+		UserID:    userID,
+		TokenType: TokenTypeRefresh,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			ExpiresAt: time.Now().Add(RefreshTokenTTL).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
 	}
+
+	return signClaims(&claims)
+}
+
+// MFAChallengeTTL is the time a user has to enter their second factor after a successful password check.
+const MFAChallengeTTL = 5 * time.Minute
+
+// GenerateMFAChallengeToken generates the short-lived token returned by the login step when
+// two-factor authentication is enabled. It can only be exchanged for real tokens together with a valid code.
+func GenerateMFAChallengeToken(userID ulid.ULID) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		TokenType: TokenTypeMFAChallenge,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
+			ExpiresAt: time.Now().Add(MFAChallengeTTL).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return signClaims(&claims)
 }
 
 // JWTMiddleware is a middleware that checks for a valid JWT token in the Authorization header of the request.
+// Only access tokens are accepted, and the session validator (if any) must accept them.
 // If the token is valid, it extracts the user ID and role from the token and stores them in the Locals of the request.
 // It also extracts the permissions for the given role and stores them in the Locals.
 // If the token is invalid or missing, it returns a 401 status code with an appropriate error message.
+// Personal API keys are accepted instead of a JWT on the routes registered with AllowAPIKey.
+// Fiber runs the middleware of every group matching the request, so a request that was already
+// authenticated by an enclosing group is not validated again.
 func JWTMiddleware(c *fiber.Ctx) error {
+	// Clé d'API : acceptée uniquement sur les routes déclarées via AllowAPIKey
+	if key := apiKeyFromRequest(c); key != "" {
+		return authenticateAPIKey(c, key)
+	}
+
+	if _, ok := c.Locals("user_id").(string); ok {
+		return c.Next()
+	}
+
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing token"})
@@ -103,15 +170,101 @@ func JWTMiddleware(c *fiber.Ctx) error {
 	}
 	tokenString := authParts[1]
 
+	if err := authenticateAccessToken(c, tokenString); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Next()
+}
+
+// WebSocketMiddleware authenticates a WebSocket upgrade request.
+// Browsers cannot set headers on a WebSocket handshake, so the access token is read from the
+// Authorization header or, failing that, from the "token" query parameter.
+// The same checks as JWTMiddleware apply, and the user ID is stored in the Locals for the connection.
+// Requests that are not WebSocket upgrades are rejected with 426.
+func WebSocketMiddleware(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{"error": "WebSocket upgrade required"})
+	}
+
+	tokenString := c.Query("token")
+	if authParts := strings.Split(c.Get("Authorization"), " "); len(authParts) == 2 {
+		tokenString = authParts[1]
+	}
+	if tokenString == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing token"})
+	}
+
+	if err := authenticateAccessToken(c, tokenString); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Next()
+}
+
+// authenticateAccessToken checks that tokenString is a valid access token for a live session,
+// then stores the user ID, session ID, role and permissions in the Locals.
+func authenticateAccessToken(c *fiber.Ctx, tokenString string) error {
 	claims, err := ParseToken(tokenString)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+	if err != nil || claims.TokenType != TokenTypeAccess {
+		return errors.New("Invalid token")
+	}
+
+	if sessionValidator != nil {
+		if err := sessionValidator(claims); err != nil {
+			return err
+		}
 	}
 
 	permissions := helpers.GetPermissions(claims.Role)
 
 	c.Locals("user_id", claims.UserID.String())
+	c.Locals("session_id", claims.SessionID)
 	c.Locals("user_role", claims.Role)
 	c.Locals("permissions", permissions)
-	return c.Next()
+	return nil
+}
+
+// RequireRole restreint une route aux utilisateurs ayant l'un des rôles donnés.
+//
+// Le rôle est relu via lookup (et non depuis le token) afin qu'un changement de rôle
+// prenne effet immédiatement. Doit être placé après JWTMiddleware.
+func RequireRole(lookup func(userID string) (models.Role, error), roles ...models.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(string)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+		}
+
+		role, err := lookup(userID)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+		}
+
+		for _, r := range roles {
+			if role == r {
+				c.Locals("user_role", role)
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
+	}
+}
+
+// RequireConfirmedEmail restreint une route aux utilisateurs ayant confirmé leur adresse email.
+// Doit être placé après JWTMiddleware.
+func RequireConfirmedEmail(lookup func(userID string) (bool, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(string)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+		}
+
+		confirmed, err := lookup(userID)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+		}
+		if !confirmed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Email not confirmed"})
+		}
+		return c.Next()
+	}
 }
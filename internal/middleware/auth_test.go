@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oklog/ulid/v2"
+)
+
+// stackedApp registers JWTMiddleware on three groups matching the same route, as routes.go does for /api
+func stackedApp() *fiber.App {
+	app := fiber.New()
+	app.Group("/api").Use(JWTMiddleware)
+	app.Group("/api/events").Use(JWTMiddleware)
+	events := app.Group("/api/events")
+	events.Use(JWTMiddleware)
+	events.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("user_id").(string))
+	})
+	return app
+}
+
+func TestJWTMiddlewareValidatesOnce(t *testing.T) {
+	t.Setenv("JWT_PRIVATE_KEY_FILE", "")
+	t.Setenv("JWT_PUBLIC_KEY_FILES", "")
+	t.Setenv("ALLOW_EPHEMERAL_KEYS", "true")
+	if err := LoadKeys(); err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	validations := 0
+	SetSessionValidator(func(*Claims) error {
+		validations++
+		return nil
+	})
+	t.Cleanup(func() { SetSessionValidator(nil) })
+
+	token, err := GenerateToken(ulid.Make(), ulid.Make().String())
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+	req := httptest.NewRequest(fiber.MethodGet, "/api/events/", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, err := stackedApp().Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if validations != 1 {
+		t.Errorf("session validated %d times, want 1", validations)
+	}
+}
@@ -0,0 +1,255 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Valeurs par défaut des claims iss et aud, surchargées par JWT_ISSUER et JWT_AUDIENCE
+const (
+	defaultIssuer   = "freemusic"
+	defaultAudience = "freemusic-api"
+)
+
+// verificationKey est une clé publique acceptée pour vérifier un token, avec l'algorithme qui lui est associé
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// keySet contient la clé de signature courante et toutes les clés de vérification actives
+type keySet struct {
+	signingKID    string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.Signer
+	verification  map[string]verificationKey
+	issuer        string
+	audience      string
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   *keySet
+)
+
+// LoadKeys charge les clés de signature des JWT. À appeler une fois au démarrage.
+//
+//   - JWT_PRIVATE_KEY_FILE : clé privée PEM (RSA pour RS256, Ed25519 pour EdDSA) utilisée pour signer.
+//   - JWT_PUBLIC_KEY_FILES : clés publiques PEM supplémentaires, séparées par des virgules, encore
+//     acceptées en vérification (anciennes clés pendant une rotation, ou prochaine clé à publier).
+//   - JWT_ISSUER / JWT_AUDIENCE : valeurs des claims iss et aud.
+//
+// Le kid de chaque clé est l'empreinte de sa clé publique, identique pour tous les services.
+// Sans JWT_PRIVATE_KEY_FILE, le démarrage échoue, sauf si ALLOW_EPHEMERAL_KEYS=true : une clé Ed25519
+// éphémère est alors générée (développement uniquement : les tokens émis deviennent invalides au redémarrage).
+func LoadKeys() error {
+	set := &keySet{
+		verification: make(map[string]verificationKey),
+		issuer:       envOrDefault("JWT_ISSUER", defaultIssuer),
+		audience:     envOrDefault("JWT_AUDIENCE", defaultAudience),
+	}
+
+	var signer crypto.Signer
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		signer, err = parsePrivateKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	} else {
+		if !EphemeralKeysAllowed() {
+			return errors.New("JWT_PRIVATE_KEY_FILE not set (set ALLOW_EPHEMERAL_KEYS=true to use an ephemeral key in development)")
+		}
+		log.Println("JWT_PRIVATE_KEY_FILE not set, using an ephemeral Ed25519 signing key")
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		signer = private
+	}
+
+	signingKey, err := newVerificationKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	set.signingKID = signingKey.kid
+	set.signingMethod = signingKey.method
+	set.signingKey = signer
+	set.verification[signingKey.kid] = signingKey
+
+	for _, path := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		public, err := parsePublicKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		set.verification[key.kid] = key
+	}
+
+	keysMu.Lock()
+	keys = set
+	keysMu.Unlock()
+	return nil
+}
+
+// EphemeralKeysAllowed indique si des clés éphémères peuvent remplacer les clés de signature
+// non configurées (ALLOW_EPHEMERAL_KEYS=true, développement uniquement)
+func EphemeralKeysAllowed() bool {
+	return os.Getenv("ALLOW_EPHEMERAL_KEYS") == "true"
+}
+
+// currentKeys retourne le jeu de clés chargé par LoadKeys
+func currentKeys() (*keySet, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if keys == nil {
+		return nil, errors.New("JWT keys not loaded")
+	}
+	return keys, nil
+}
+
+// signClaims signe des claims avec la clé courante, en renseignant kid, iss et aud
+func signClaims(claims *Claims) (string, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+	claims.Issuer = set.issuer
+	claims.Audience = set.audience
+
+	token := jwt.NewWithClaims(set.signingMethod, claims)
+	token.Header["kid"] = set.signingKID
+	return token.SignedString(set.signingKey)
+}
+
+// verificationKeyFunc sélectionne la clé par kid et impose l'algorithme associé à cette clé
+func verificationKeyFunc(set *keySet) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := set.verification[kid]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.public, nil
+	}
+}
+
+// JWKS retourne les clés publiques de vérification au format JSON Web Key Set
+func JWKS() (map[string]interface{}, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := make([]map[string]string, 0, len(set.verification))
+	for _, key := range set.verification {
+		jwk := map[string]string{
+			"kid": key.kid,
+			"use": "sig",
+			"alg": key.method.Alg(),
+		}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return map[string]interface{}{"keys": jwks}, nil
+}
+
+// newVerificationKey associe une clé publique à son algorithme et calcule son kid
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, errors.New("unsupported key type, expected RSA or Ed25519")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return verificationKey{}, err
+	}
+	sum := sha256.Sum256(der)
+
+	return verificationKey{
+		kid:    base64.RawURLEncoding.EncodeToString(sum[:12]),
+		method: method,
+		public: public,
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// APIKeyScope limite une clé d'API à une famille d'opérations sur les événements
+type APIKeyScope string
+
+const (
+	ScopeEventsRead    APIKeyScope = "events:read"    // Consulter ses événements, occurrences, imports et exports
+	ScopeEventsWrite   APIKeyScope = "events:write"   // Créer, modifier, reprogrammer et importer des événements
+	ScopeEventsPublish APIKeyScope = "events:publish" // Publier, dépublier et annuler des événements
+)
+
+// APIKeyScopes liste les scopes pouvant être attribués à une clé
+var APIKeyScopes = []APIKeyScope{ScopeEventsRead, ScopeEventsWrite, ScopeEventsPublish}
+
+// APIKey est une clé d'API personnelle permettant à un script d'agir au nom de l'utilisateur.
+//
+// Seule l'empreinte SHA-256 de la clé est stockée ; Prefix (visible dans la clé elle-même)
+// permet de la retrouver et de l'identifier dans l'interface sans exposer le secret.
+type APIKey struct {
+	ID         string     `gorm:"primaryKey;type:varchar(26)" json:"id"`
+	UserID     string     `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"not null" json:"scopes"` // Scopes séparés par des virgules
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ArtistFollow représente un utilisateur qui suit un artiste.
+type ArtistFollow struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"type:varchar(26);not null;uniqueIndex:idx_artist_follow" json:"user_id"`
+	ArtistID  int       `gorm:"not null;uniqueIndex:idx_artist_follow;index" json:"artist_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
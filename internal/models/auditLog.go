@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// AuditAction identifie une action d'administration tracée dans le journal d'audit
+type AuditAction string
+
+const (
+	AuditRoleChanged         AuditAction = "user.role_changed"
+	AuditUserSuspended       AuditAction = "user.suspended"
+	AuditUserReinstated      AuditAction = "user.reinstated"
+	AuditPasswordResetForced AuditAction = "user.password_reset_forced"
+	AuditUserUnlocked        AuditAction = "user.unlocked"
+	AuditActivityViewed      AuditAction = "user.activity_viewed"
+	AuditOrganizerApproved   AuditAction = "organizer.approved"
+	AuditOrganizerRejected   AuditAction = "organizer.rejected"
+)
+
+// AuditLog trace une action d'administration sur un compte utilisateur.
+// Les entrées ne sont jamais modifiées ni supprimées, y compris à la suppression du compte visé.
+type AuditLog struct {
+	ID           string      `gorm:"primaryKey;type:varchar(26)" json:"id"`
+	ActorID      string      `gorm:"type:varchar(26);not null;index" json:"actor_id"`
+	Action       AuditAction `gorm:"type:varchar(40);not null;index" json:"action"`
+	TargetUserID string      `gorm:"type:varchar(26);index" json:"target_user_id"`
+	Reason       string      `gorm:"type:text" json:"reason,omitempty"`
+	Details      string      `gorm:"type:text" json:"details,omitempty"` // Valeurs avant/après, au format JSON
+	IP           string      `gorm:"size:45" json:"ip"`
+	CreatedAt    time.Time   `gorm:"index" json:"created_at"`
+}
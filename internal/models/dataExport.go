@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type DataExportStatus string
+
+const (
+	ExportPending   DataExportStatus = "pending"
+	ExportRunning   DataExportStatus = "running"
+	ExportCompleted DataExportStatus = "completed"
+	ExportFailed    DataExportStatus = "failed"
+	ExportExpired   DataExportStatus = "expired" // Archive supprimée après la durée de conservation
+)
+
+// DataExport suit la génération, en tâche de fond, de l'archive des données personnelles d'un utilisateur (RGPD).
+// L'archive est supprimée à ExpiresAt ; elle n'est téléchargeable que par un lien signé de courte durée.
+type DataExport struct {
+	ID          string           `json:"id" gorm:"primaryKey;type:varchar(26)"`
+	UserID      string           `json:"user_id" gorm:"type:varchar(26);index;not null"`
+	Status      DataExportStatus `json:"status" gorm:"type:varchar(20)"`
+	FilePath    string           `json:"-"`
+	SizeBytes   int64            `json:"size_bytes"`
+	Message     string           `json:"message"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	CompletedAt *time.Time       `json:"completed_at"`
+	ExpiresAt   *time.Time       `json:"expires_at"` // Date de suppression de l'archive
+}
@@ -13,11 +13,33 @@ const (
 	Ongoing   Status = "ongoing"
 	Completed Status = "completed"
 	Expired   Status = "expired"
+	Cancelled Status = "cancelled"
+)
+
+// PublicationState représente l'étape du cycle de publication d'un événement.
+type PublicationState string
+
+const (
+	StateDraft         PublicationState = "draft"
+	StatePendingReview PublicationState = "pending_review"
+	StateScheduled     PublicationState = "scheduled"
+	StatePublished     PublicationState = "published"
+	StateRejected      PublicationState = "rejected"
+)
+
+// Visibility détermine qui peut voir un événement publié.
+type Visibility string
+
+const (
+	VisibilityPublic   Visibility = "public"   // Listé et accessible à tous
+	VisibilityUnlisted Visibility = "unlisted" // Accessible uniquement par lien direct
+	VisibilityFriends  Visibility = "friends"  // Réservé aux amis de l'organisateur
 )
 
 type Event struct {
 	ID            int64          `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID        int64          `gorm:"not null"`
+	OrganizerID   string         `gorm:"type:varchar(26);index"` // Utilisateur (ULID) qui organise l'événement
 	Title         string         `gorm:"null"`
 	Description   string         `gorm:"null"`
 	LocationID    string         `gorm:"null"`
@@ -34,4 +56,17 @@ type Event struct {
 	ArtistID      int            `gorm:"null"`
 	CategoryIds   string         `gorm:"type:json"` // Stockage des catégories sous forme de JSON
 	GalleryImages string         `gorm:"type:json"` // Stockage des images sous forme de JSON
+	RRule         string         `gorm:"null"`      // Règle de récurrence RFC 5545 (ex: FREQ=WEEKLY;BYDAY=TH)
+	TimeZone      string         `gorm:"null"`      // Fuseau IANA utilisé pour développer les occurrences (ex: Europe/Paris)
+	ParentEventID *int64         `gorm:"index"`     // Série d'origine lorsqu'une série est scindée ("cette occurrence et les suivantes")
+
+	// Les événements existants restent publiés ; les nouveaux sont créés en brouillon par EventService
+	PublicationState PublicationState `gorm:"type:varchar(20);default:'published';index"`
+	Visibility       Visibility       `gorm:"type:varchar(20);default:'public'"`
+	PublishAt        *time.Time       `gorm:"index"` // Publication programmée
+	PublishedAt      *time.Time
+	ReviewNote       string `gorm:"null"` // Motif de rejet laissé par un administrateur
+
+	// Badge de l'organisateur, renseigné par EventService à la lecture
+	OrganizerVerified bool `gorm:"-" json:"organizer_verified"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// EventException représente une modification ponctuelle d'une occurrence d'un événement récurrent :
+// annulation, report ou surcharge de certains champs pour cette date uniquement.
+type EventException struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventID        int64      `gorm:"not null;uniqueIndex:idx_event_exception_occurrence" json:"event_id"`
+	OccurrenceDate time.Time  `gorm:"not null;uniqueIndex:idx_event_exception_occurrence" json:"occurrence_date"` // Début initial de l'occurrence
+	Cancelled      bool       `gorm:"default:false" json:"cancelled"`
+	StartTime      *time.Time `json:"start_time"`
+	EndTime        *time.Time `json:"end_time"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Address        string     `json:"address"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type EventAction string
+
+const (
+	ActionCancelled   EventAction = "cancelled"
+	ActionRescheduled EventAction = "rescheduled"
+	ActionTransferred EventAction = "transferred" // Repris par un autre organisateur
+)
+
+// EventHistory trace publiquement les changements importants d'un événement (annulation, report, transfert).
+type EventHistory struct {
+	ID            int64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventID       int64       `gorm:"not null;index" json:"event_id"`
+	Action        EventAction `gorm:"type:varchar(20);not null" json:"action"`
+	Reason        string      `json:"reason"`
+	PreviousStart *time.Time  `json:"previous_start,omitempty"`
+	PreviousEnd   *time.Time  `json:"previous_end,omitempty"`
+	NewStart      *time.Time  `json:"new_start,omitempty"`
+	NewEnd        *time.Time  `json:"new_end,omitempty"`
+	ActorID       string      `gorm:"type:varchar(26)" json:"-"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
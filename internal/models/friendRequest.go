@@ -2,6 +2,8 @@ package models
 
 import "time"
 
+// FriendRequest est la relation entre deux utilisateurs : demande en attente, amitié acceptée ou demande refusée.
+// Une paire n'a qu'une seule ligne, quel que soit le sens (index idx_friend_requests_pair, voir storage.MigrateFriendRequests).
 type FriendRequest struct {
 	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
 	SenderId   string     `json:"sender_id" gorm:"type:varchar(26)"`
@@ -11,6 +13,10 @@ type FriendRequest struct {
 	UpdatedAt  time.Time  `json:"updated_at"`
 	DeletedAt  *time.Time `json:"deleted_at"`
 
-	Sender   Users `gorm:"foreignKey:SenderId" json:"sender"`
-	Receiver Users `gorm:"foreignKey:ReceiverId" json:"receiver"`
+	Sender   Users `gorm:"foreignKey:SenderId" json:"-"`
+	Receiver Users `gorm:"foreignKey:ReceiverId" json:"-"`
+
+	// Profils publics renseignés dans les réponses, selon les réglages de visibilité de chacun
+	SenderProfile   *PublicUser `gorm:"-" json:"sender,omitempty"`
+	ReceiverProfile *PublicUser `gorm:"-" json:"receiver,omitempty"`
 }
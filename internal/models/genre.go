@@ -1,7 +1,15 @@
 package models
 
+// GenreCatalogue liste les genres proposés dans les profils musicaux. Ils sont insérés au démarrage
+// (storage.MigrateMusicProfiles) afin d'exister en production comme en développement.
+var GenreCatalogue = []string{
+	"Rock", "Pop", "Jazz", "Blues", "Classique", "Électro", "Hip-hop", "Rap",
+	"R&B", "Soul", "Funk", "Reggae", "Metal", "Punk", "Folk", "Country",
+	"Musique latine", "Musiques du monde", "Indie", "Chanson française",
+}
+
 type Genre struct {
 	ID     int     `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name   string  `gorm:"size:100;not null;unique"`
-	Events []Event `gorm:"many2many:event_genres;"`
+	Name   string  `gorm:"size:100;not null;unique" json:"name"`
+	Events []Event `gorm:"many2many:event_genres;" json:"-"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ImportJobStatus string
+
+const (
+	ImportPending   ImportJobStatus = "pending"
+	ImportRunning   ImportJobStatus = "running"
+	ImportCompleted ImportJobStatus = "completed"
+	ImportFailed    ImportJobStatus = "failed"
+)
+
+// ImportJob suit l'avancement d'un import d'événements en masse exécuté en tâche de fond.
+type ImportJob struct {
+	ID            string          `json:"id" gorm:"primaryKey;type:varchar(26)"`
+	OrganizerID   string          `json:"organizer_id" gorm:"type:varchar(26);index;not null"`
+	Format        string          `json:"format" gorm:"type:varchar(10)"`
+	DryRun        bool            `json:"dry_run"`
+	Status        ImportJobStatus `json:"status" gorm:"type:varchar(20)"`
+	TotalRows     int             `json:"total_rows"`
+	ProcessedRows int             `json:"processed_rows"`
+	ImportedRows  int             `json:"imported_rows"`
+	Errors        json.RawMessage `json:"errors" gorm:"type:jsonb"` // Erreurs par ligne : [{"row": 3, "errors": ["..."]}]
+	Message       string          `json:"message"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
+}
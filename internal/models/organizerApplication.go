@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type ApplicationStatus string
+
+const (
+	ApplicationPending  ApplicationStatus = "pending"
+	ApplicationApproved ApplicationStatus = "approved"
+	ApplicationRejected ApplicationStatus = "rejected"
+)
+
+// OrganizerApplication est la demande d'un utilisateur pour devenir organisateur vérifié.
+// Les justificatifs sont enregistrés par ImageService et ne sont accessibles qu'aux administrateurs.
+type OrganizerApplication struct {
+	ID           string            `gorm:"primaryKey;type:varchar(26)" json:"id"`
+	UserID       string            `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	Organization string            `gorm:"size:200;not null" json:"organization"`
+	Website      string            `gorm:"size:500" json:"website"`
+	Description  string            `gorm:"type:text" json:"description"`
+	Documents    string            `gorm:"type:text" json:"-"` // Fichiers téléversés, séparés par des virgules
+	Status       ApplicationStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ReviewerID   string            `gorm:"type:varchar(26)" json:"-"`
+	ReviewNote   string            `gorm:"type:text" json:"review_note,omitempty"` // Motif du refus, communiqué au demandeur
+	ReviewedAt   *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+
+	// Files est renseigné à la lecture à partir de Documents
+	Files []string `gorm:"-" json:"documents"`
+}
+
+// DocumentList retourne les noms des justificatifs téléversés
+func (a OrganizerApplication) DocumentList() []string {
+	files := []string{}
+	for _, name := range strings.Split(a.Documents, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			files = append(files, name)
+		}
+	}
+	return files
+}
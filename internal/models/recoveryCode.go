@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode est un code de secours à usage unique permettant de se connecter sans l'application TOTP.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    string     `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RefreshToken est l'enregistrement côté serveur d'un refresh token émis.
+//
+// L'ID correspond au claim jti du JWT. Chaque rotation crée un nouveau token dans la même
+// famille (FamilyID) ; la réutilisation d'un token déjà consommé révoque toute la famille.
+type RefreshToken struct {
+	ID           string     `gorm:"primaryKey;type:varchar(26)" json:"id"`
+	UserID       string     `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	FamilyID     string     `gorm:"type:varchar(26);not null;index" json:"family_id"`
+	TokenHash    string     `gorm:"size:64;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID string     `gorm:"type:varchar(26)" json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
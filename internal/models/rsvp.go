@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type RSVPStatus string
+
+const (
+	RSVPGoing      RSVPStatus = "going"
+	RSVPInterested RSVPStatus = "interested"
+)
+
+// RSVP représente la participation (ou l'intérêt) d'un utilisateur pour un événement.
+type RSVP struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventID   int64      `gorm:"not null;uniqueIndex:idx_rsvp_event_user" json:"event_id"`
+	UserID    string     `gorm:"type:varchar(26);not null;uniqueIndex:idx_rsvp_event_user;index" json:"user_id"`
+	Status    RSVPStatus `gorm:"type:varchar(20);not null" json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
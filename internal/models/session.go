@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Session représente un appareil connecté au compte d'un utilisateur.
+//
+// L'ID est celui de la famille de refresh tokens (RefreshToken.FamilyID) ouverte à la connexion :
+// révoquer la session révoque la famille, et les tokens d'accès portant ce sid sont refusés.
+type Session struct {
+	ID          string     `gorm:"primaryKey;type:varchar(26)" json:"id"`
+	UserID      string     `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	DeviceLabel string     `gorm:"size:100" json:"device_label"`
+	UserAgent   string     `gorm:"size:512" json:"user_agent"`
+	IP          string     `gorm:"size:45" json:"ip"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	// Current est renseigné dans les réponses pour signaler la session de l'appelant
+	Current bool `gorm:"-" json:"current"`
+}
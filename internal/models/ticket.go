@@ -2,13 +2,28 @@ package models
 
 import "time"
 
+type PaymentStatus string
+
+const (
+	PaymentNone          PaymentStatus = ""               // Billet gratuit
+	PaymentPaid          PaymentStatus = "paid"           // Billet payé
+	PaymentRefundPending PaymentStatus = "refund_pending" // Remboursement demandé au prestataire de paiement
+	PaymentRefunded      PaymentStatus = "refunded"       // Remboursement effectué
+)
+
 type Ticket struct {
-	ID            uint `gorm:"primaryKey"`
-	UserID        uint `gorm:"not null"`
-	User          Users
-	EventID       uint `gorm:"not null"`
-	Event         Event
-	PurchaseDate  time.Time `gorm:"not null"`
-	SeatNumber    *string
-	ReservationID uint
+	ID                uint   `gorm:"primaryKey"`
+	UserID            string `gorm:"type:varchar(26);not null;index"`
+	User              Users
+	EventID           uint `gorm:"not null;index"`
+	Event             Event
+	PurchaseDate      time.Time `gorm:"not null"`
+	SeatNumber        *string
+	ReservationID     uint
+	PriceCents        int64         `gorm:"default:0"`
+	Currency          string        `gorm:"type:varchar(3)"`
+	PaymentStatus     PaymentStatus `gorm:"type:varchar(20)"`
+	PaymentReference  string        // Référence de la transaction chez le prestataire de paiement
+	RefundRequestedAt *time.Time
+	RefundedAt        *time.Time
 }
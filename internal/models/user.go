@@ -12,28 +12,83 @@ const (
 	RoleUser      Role = "user"
 )
 
+// ProfileVisibility détermine qui peut voir un champ du profil
+type ProfileVisibility string
+
+const (
+	ProfileVisibleToEveryone ProfileVisibility = "everyone" // Tout utilisateur authentifié
+	ProfileVisibleToFriends  ProfileVisibility = "friends"  // Amis uniquement
+	ProfileVisibleToNobody   ProfileVisibility = "nobody"   // Personne d'autre que l'utilisateur
+)
+
 type Users struct {
 	ID                     string          `json:"id" gorm:"primaryKey;type:varchar(26)"`
 	Username               string          `json:"username"`
 	Email                  string          `json:"email" gorm:"unique"`
-	PasswordHash           string          `json:"password_hash"`
+	PasswordHash           string          `json:"-"`
 	UpdatedAt              time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt              *time.Time      `json:"deleted_at" gorm:"index"`
 	BirthDate              *time.Time      `json:"birth_date"`
 	ProfilePhoto           string          `json:"profile_photo"`
-	FavoriteSport          string          `json:"favorite_sport"`
 	Location               string          `json:"location"`
 	Latitude               float64         `json:"latitude"`
 	Longitude              float64         `json:"longitude"`
-	SkillLevel             string          `json:"skill_level"`
 	Bio                    string          `json:"bio"`
-	RefreshToken           string          `json:"refresh_token"`
+	Passwordless           bool            `json:"-" gorm:"default:false"` // Compte créé via un fournisseur externe, sans mot de passe choisi
+	PasswordResetTokenHash string          `json:"-" gorm:"size:64;index"` // Empreinte du jeton de réinitialisation, à usage unique
+	PasswordResetExpiresAt *time.Time      `json:"-"`
+	TokensRevokedAt        *time.Time      `json:"-"` // Les tokens d'accès émis avant cette date sont refusés ("déconnexion partout")
 	IsConfirmed            bool            `json:"is_confirmed" gorm:"default:false"`
-	ConfirmationToken      string          `json:"confirmation_token" gorm:"size:255"`
+	ConfirmationToken      string          `json:"-" gorm:"size:255;index"` // Empreinte du jeton de confirmation
 	TokenExpiresAt         *time.Time      `json:"token_expires_at"`
+	ConfirmationSentAt     *time.Time      `json:"-"`                       // Dernier envoi, pour limiter les renvois
+	PendingEmail           string          `json:"pending_email,omitempty"` // Nouvelle adresse en attente de vérification
+	EmailChangeTokenHash   string          `json:"-" gorm:"size:64;index"`
+	EmailChangeExpiresAt   *time.Time      `json:"-"`
+	TOTPSecret             string          `json:"-"` // Secret TOTP (en attente tant que TOTPEnabled est faux)
+	TOTPEnabled            bool            `json:"totp_enabled" gorm:"default:false"`
+	TOTPLastStep           int64           `json:"-"` // Dernière période TOTP acceptée, pour refuser la réutilisation d'un code
 	Role                   Role            `json:"role" gorm:"default:'user'"`
 	SentFriendRequests     []FriendRequest `json:"sent_friend_requests" gorm:"foreignKey:SenderId"`
 	ReceivedFriendRequests []FriendRequest `json:"received_friend_requests" gorm:"foreignKey:ReceiverId"`
 
 	FCMToken string `json:"fcm_token"`
+
+	CalendarTokenHash string `json:"-" gorm:"size:64;index"` // Empreinte du jeton du flux iCalendar, révocable
+
+	// Suppression du compte demandée : elle est exécutée à DeletionScheduledAt, sauf annulation d'ici là
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" gorm:"index"`
+	DeletionTransferTo  string     `json:"deletion_transfer_to,omitempty" gorm:"type:varchar(26)"` // Ami qui reprendra les événements à venir
+
+	// Profil musical (voir MusicProfileService)
+	FavoriteGenres       []Genre  `json:"favorite_genres" gorm:"many2many:user_favorite_genres;joinForeignKey:UserID;joinReferences:GenreID"`
+	FavoriteArtists      []Artist `json:"favorite_artists" gorm:"many2many:user_favorite_artists;joinForeignKey:UserID;joinReferences:ArtistID"`
+	Instruments          string   `json:"instruments" gorm:"size:600"` // Instruments pratiqués, séparés par des virgules
+	ListeningPreferences string   `json:"listening_preferences" gorm:"type:text"`
+
+	OrganizerVerifiedAt *time.Time `json:"-"` // Candidature d'organisateur validée (badge vérifié)
+
+	// Sanctions d'administration : suspension jusqu'à SuspendedUntil, ou bannissement si SuspendedUntil est nul
+	SuspendedAt           *time.Time `json:"-"`
+	SuspendedUntil        *time.Time `json:"-"`
+	SuspensionReason      string     `json:"-"`
+	PasswordResetRequired bool       `json:"-" gorm:"default:false"` // Connexion par mot de passe refusée jusqu'à la réinitialisation
+
+	// Visibilité des champs du profil pour les autres utilisateurs (voir PublicUser)
+	EmailVisibility     ProfileVisibility `json:"email_visibility" gorm:"type:varchar(10);default:'nobody'"`
+	LocationVisibility  ProfileVisibility `json:"location_visibility" gorm:"type:varchar(10);default:'everyone'"`
+	BirthDateVisibility ProfileVisibility `json:"birth_date_visibility" gorm:"type:varchar(10);default:'friends'"`
+	BioVisibility       ProfileVisibility `json:"bio_visibility" gorm:"type:varchar(10);default:'everyone'"`
+}
+
+// IsSuspended indique si le compte est suspendu ou banni à la date donnée
+func (u Users) IsSuspended(now time.Time) bool {
+	return u.SuspendedAt != nil && (u.SuspendedUntil == nil || u.SuspendedUntil.After(now))
+}
+
+// IsVerifiedOrganizer indique si l'utilisateur porte le badge d'organisateur vérifié.
+// Le badge est retiré de fait si le rôle d'organisateur lui est ensuite repris.
+func (u Users) IsVerifiedOrganizer() bool {
+	return u.OrganizerVerifiedAt != nil && (u.Role == RoleOrganizer || u.Role == RoleAdmin)
 }
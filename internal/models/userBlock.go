@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// UserBlock représente un utilisateur qui en a bloqué un autre.
+// Le blocage s'applique dans les deux sens : recherche, demandes d'amis, messages et notifications.
+type UserBlock struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BlockerID string    `gorm:"type:varchar(26);not null;uniqueIndex:idx_user_block" json:"blocker_id"`
+	BlockedID string    `gorm:"type:varchar(26);not null;uniqueIndex:idx_user_block;index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity relie un compte à une identité externe (fournisseur OpenID Connect).
+// Un même compte peut être relié à plusieurs fournisseurs.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"type:varchar(26);not null;index" json:"user_id"`
+	Provider  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// publicCoordinateScale arrondit les coordonnées montrées aux autres utilisateurs au centième de degré
+// (environ un kilomètre) : la position exacte ne sort pas de la vue privée
+const publicCoordinateScale = 100
+
+// ProfileVisibilities liste les niveaux de visibilité acceptés
+var ProfileVisibilities = []ProfileVisibility{ProfileVisibleToEveryone, ProfileVisibleToFriends, ProfileVisibleToNobody}
+
+// Valid indique si la valeur fait partie des niveaux de visibilité connus
+func (v ProfileVisibility) Valid() bool {
+	for _, known := range ProfileVisibilities {
+		if v == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows indique si un autre utilisateur (ami ou non) peut voir le champ.
+// Une valeur vide (compte antérieur aux réglages) est traitée comme "nobody".
+func (v ProfileVisibility) Allows(isFriend bool) bool {
+	switch v {
+	case ProfileVisibleToEveryone:
+		return true
+	case ProfileVisibleToFriends:
+		return isFriend
+	default:
+		return false
+	}
+}
+
+// PrivacySettings regroupe les réglages de visibilité du profil
+type PrivacySettings struct {
+	EmailVisibility     ProfileVisibility `json:"email_visibility"`
+	LocationVisibility  ProfileVisibility `json:"location_visibility"`
+	BirthDateVisibility ProfileVisibility `json:"birth_date_visibility"`
+	BioVisibility       ProfileVisibility `json:"bio_visibility"`
+}
+
+// PublicUser est la vue d'un profil renvoyée aux autres utilisateurs.
+// Les champs soumis à un réglage de visibilité sont omis lorsque l'appelant n'y a pas accès.
+type PublicUser struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	ProfilePhoto string     `json:"profile_photo"`
+	Role         Role       `json:"role"`
+	Verified     bool       `json:"verified_organizer"`
+	Email        string     `json:"email,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+	BirthDate    *time.Time `json:"birth_date,omitempty"`
+	Bio          string     `json:"bio,omitempty"`
+	IsFriend     bool       `json:"is_friend"`
+	MusicProfile
+}
+
+// MusicProfile regroupe les goûts et la pratique musicale d'un utilisateur
+type MusicProfile struct {
+	FavoriteGenres       []GenreSummary  `json:"favorite_genres"`
+	FavoriteArtists      []ArtistSummary `json:"favorite_artists"`
+	Instruments          []string        `json:"instruments"`
+	ListeningPreferences string          `json:"listening_preferences"`
+}
+
+// GenreSummary est la forme abrégée d'un genre dans un profil
+type GenreSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ArtistSummary est la forme abrégée d'un artiste dans un profil
+type ArtistSummary struct {
+	ArtistID int    `json:"artist_id"`
+	Name     string `json:"name"`
+}
+
+// PrivateUser est la vue complète du profil, réservée à son propriétaire
+type PrivateUser struct {
+	ID                  string          `json:"id"`
+	Username            string          `json:"username"`
+	Email               string          `json:"email"`
+	PendingEmail        string          `json:"pending_email,omitempty"`
+	IsConfirmed         bool            `json:"is_confirmed"`
+	Role                Role            `json:"role"`
+	Verified            bool            `json:"verified_organizer"`
+	ProfilePhoto        string          `json:"profile_photo"`
+	Location            string          `json:"location"`
+	Latitude            float64         `json:"latitude"`
+	Longitude           float64         `json:"longitude"`
+	BirthDate           *time.Time      `json:"birth_date"`
+	Bio                 string          `json:"bio"`
+	TOTPEnabled         bool            `json:"totp_enabled"`
+	Privacy             PrivacySettings `json:"privacy"`
+	DeletionScheduledAt *time.Time      `json:"deletion_scheduled_at,omitempty"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	MusicProfile
+}
+
+// Privacy retourne les réglages de visibilité de l'utilisateur
+func (u Users) Privacy() PrivacySettings {
+	return PrivacySettings{
+		EmailVisibility:     u.EmailVisibility,
+		LocationVisibility:  u.LocationVisibility,
+		BirthDateVisibility: u.BirthDateVisibility,
+		BioVisibility:       u.BioVisibility,
+	}
+}
+
+// InstrumentList retourne les instruments pratiqués sous forme de liste
+func (u Users) InstrumentList() []string {
+	instruments := []string{}
+	for _, instrument := range strings.Split(u.Instruments, ",") {
+		if instrument = strings.TrimSpace(instrument); instrument != "" {
+			instruments = append(instruments, instrument)
+		}
+	}
+	return instruments
+}
+
+// Music retourne le profil musical de l'utilisateur.
+// FavoriteGenres et FavoriteArtists doivent avoir été préchargés pour apparaître.
+func (u Users) Music() MusicProfile {
+	profile := MusicProfile{
+		FavoriteGenres:       make([]GenreSummary, 0, len(u.FavoriteGenres)),
+		FavoriteArtists:      make([]ArtistSummary, 0, len(u.FavoriteArtists)),
+		Instruments:          u.InstrumentList(),
+		ListeningPreferences: u.ListeningPreferences,
+	}
+	for _, genre := range u.FavoriteGenres {
+		profile.FavoriteGenres = append(profile.FavoriteGenres, GenreSummary{ID: genre.ID, Name: genre.Name})
+	}
+	for _, artist := range u.FavoriteArtists {
+		profile.FavoriteArtists = append(profile.FavoriteArtists, ArtistSummary{ArtistID: artist.ArtistID, Name: artist.Name})
+	}
+	return profile
+}
+
+// Public construit la vue du profil destinée à un autre utilisateur, selon qu'il est ami ou non
+func (u Users) Public(isFriend bool) PublicUser {
+	view := u.publicView(func(v ProfileVisibility) bool { return v.Allows(isFriend) })
+	view.IsFriend = isFriend
+	return view
+}
+
+// OwnPublic construit la vue publique du profil pour son propriétaire, sans masquage
+func (u Users) OwnPublic() PublicUser {
+	return u.publicView(func(ProfileVisibility) bool { return true })
+}
+
+func (u Users) publicView(visible func(ProfileVisibility) bool) PublicUser {
+	view := PublicUser{
+		ID:           u.ID,
+		Username:     u.Username,
+		ProfilePhoto: u.ProfilePhoto,
+		Role:         u.Role,
+		Verified:     u.IsVerifiedOrganizer(),
+		MusicProfile: u.Music(),
+	}
+	if visible(u.EmailVisibility) {
+		view.Email = u.Email
+	}
+	if visible(u.LocationVisibility) {
+		latitude := math.Round(u.Latitude*publicCoordinateScale) / publicCoordinateScale
+		longitude := math.Round(u.Longitude*publicCoordinateScale) / publicCoordinateScale
+		view.Location = u.Location
+		view.Latitude = &latitude
+		view.Longitude = &longitude
+	}
+	if visible(u.BirthDateVisibility) {
+		view.BirthDate = u.BirthDate
+	}
+	if visible(u.BioVisibility) {
+		view.Bio = u.Bio
+	}
+	return view
+}
+
+// Private construit la vue complète du profil pour son propriétaire
+func (u Users) Private() PrivateUser {
+	return PrivateUser{
+		ID:                  u.ID,
+		Username:            u.Username,
+		Email:               u.Email,
+		PendingEmail:        u.PendingEmail,
+		IsConfirmed:         u.IsConfirmed,
+		Role:                u.Role,
+		Verified:            u.IsVerifiedOrganizer(),
+		ProfilePhoto:        u.ProfilePhoto,
+		Location:            u.Location,
+		Latitude:            u.Latitude,
+		Longitude:           u.Longitude,
+		BirthDate:           u.BirthDate,
+		Bio:                 u.Bio,
+		TOTPEnabled:         u.TOTPEnabled,
+		Privacy:             u.Privacy(),
+		DeletionScheduledAt: u.DeletionScheduledAt,
+		UpdatedAt:           u.UpdatedAt,
+		MusicProfile:        u.Music(),
+	}
+}
@@ -3,11 +3,17 @@ package routes
 import (
 	"github.com/mackenzii/freemusic/internal/controllers"
 	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/models"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
+// SetupRoutesWellKnown expose les documents publics de découverte, hors du groupe /api protégé.
+func SetupRoutesWellKnown(app *fiber.App, controller *controllers.AuthController) {
+	app.Get("/.well-known/jwks.json", controller.JWKSHandler)
+}
+
 // SetupRoutesAuth configure les routes pour l'authentification des utilisateurs.
 func SetupRoutesAuth(app *fiber.App, controller *controllers.AuthController) {
 	api := app.Group("/api")
@@ -15,31 +21,218 @@ func SetupRoutesAuth(app *fiber.App, controller *controllers.AuthController) {
 	// Routes ouvertes (sans authentification)
 	api.Post("/register", controller.RegisterHandler)
 	api.Post("/login", controller.LoginHandler)
+	api.Post("/login/2fa", controller.LoginTwoFactorHandler)
 	api.Post("/refresh", controller.RefreshHandler)
-	api.Get("/auth/google", controller.GoogleLogin)
-	api.Get("/auth/google/callback", controller.GoogleCallback)
+	api.Post("/logout", controller.LogoutHandler)
+	api.Get("/auth/providers", controller.OAuthProvidersHandler)
+	api.Get("/auth/:provider", controller.OAuthLoginHandler)
+	api.Get("/auth/:provider/callback", controller.OAuthCallbackHandler)
+	api.Post("/auth/:provider/callback", controller.OAuthCallbackHandler)
 	api.Get("/confirm_email", controller.ConfirmEmailHandler)
+	api.Post("/resend_confirmation", controller.ResendConfirmationHandler)
+	api.Post("/forgot_password", controller.ForgotPasswordHandler)
+	api.Post("/reset_password", controller.ResetPasswordHandler)
 
 	// Routes protégées (requièrent authentification)
 	api.Use(middlewares.JWTMiddleware)
+	api.Post("/logout/all", controller.LogoutAllHandler)
+	api.Get("/sessions", controller.GetSessionsHandler)
+	api.Delete("/sessions", controller.RevokeOtherSessionsHandler)
+	api.Delete("/sessions/:id", controller.RevokeSessionHandler)
+	api.Get("/identities", controller.GetIdentitiesHandler)
+	api.Post("/identities/:provider", controller.LinkIdentityHandler)
+	api.Delete("/identities/:provider", controller.UnlinkIdentityHandler)
+	api.Post("/2fa/setup", controller.SetupTwoFactorHandler)
+	api.Post("/2fa/enable", controller.EnableTwoFactorHandler)
+	api.Post("/2fa/disable", controller.DisableTwoFactorHandler)
+	api.Post("/2fa/recovery_codes", controller.RegenerateRecoveryCodesHandler)
 	api.Put("/userUpdate", controller.UserUpdate)
 	// api.Get("/userInfo", controller.GetUserInfoHandler)
 	api.Get("/users", controller.GetUsersHandler)
 	api.Get("/users/:id/public", controller.GetPublicUserInfoHandler)
-	api.Delete("/deleteMyAccount", controller.DeleteUserHandler)
+	api.Get("/privacy", controller.GetPrivacySettingsHandler)    // Visibilité de l'email, de la localisation, de la date de naissance et de la bio
+	api.Put("/privacy", controller.UpdatePrivacySettingsHandler) // everyone, friends ou nobody pour chacun de ces champs
 	// api.Post("/UpdateUserStatistics", controller.UpdateUserStatistics)
 }
 
 // SetupRoutesEvents configure les routes pour gérer les événements.
-func SetupRoutesEvents(app *fiber.App, controller *controllers.EventController) {
+// La création et la publication exigent une adresse email confirmée (voir confirmedLookup).
+func SetupRoutesEvents(app *fiber.App, controller *controllers.EventController, confirmedLookup func(string) (bool, error)) {
 	api := app.Group("/api/events")
 	api.Use(middlewares.JWTMiddleware)
+	confirmed := middlewares.RequireConfirmedEmail(confirmedLookup)
 
-	api.Get("/", controller.GetAllEvents)             // Récupérer tous les événements
-	api.Post("/createEvent/", controller.CreateEvent) // Créer un nouvel événement
-	api.Delete("/event/:id", controller.DeleteEvent)  // Supprimer un événement
+	// Opérations accessibles aux clés d'API selon leur scope (les autres routes n'acceptent que les JWT)
+	middlewares.AllowAPIKey(fiber.MethodGet, "/api/events/mine", models.ScopeEventsRead)
+	middlewares.AllowAPIKey(fiber.MethodGet, "/api/events/:event_id", models.ScopeEventsRead)
+	middlewares.AllowAPIKey(fiber.MethodGet, "/api/events/:event_id/occurrences", models.ScopeEventsRead)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/createEvent", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPut, "/api/events/:id", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPut, "/api/events/:event_id/occurrences", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/:event_id/occurrences/cancel", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPut, "/api/events/:event_id/visibility", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/:event_id/reschedule", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/:event_id/publish", models.ScopeEventsPublish)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/:event_id/unpublish", models.ScopeEventsPublish)
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/:event_id/cancel", models.ScopeEventsPublish)
+
+	api.Get("/", controller.GetAllEvents)                        // Récupérer tous les événements
+	api.Get("/occurrences", controller.GetOccurrences)           // Occurrences de tous les événements sur une période
+	api.Get("/mine", controller.GetMyEvents)                     // Événements organisés par l'utilisateur (brouillons inclus)
+	api.Post("/createEvent/", confirmed, controller.CreateEvent) // Créer un nouvel événement
+	api.Delete("/event/:id", controller.DeleteEvent)             // Supprimer un événement
 	api.Get("/:event_id", controller.GetEventByID)
 	api.Put("/:id", controller.UpdateEvent)
+	api.Get("/:event_id/occurrences", controller.GetEventOccurrences)      // Occurrences d'un événement récurrent
+	api.Put("/:event_id/occurrences", controller.UpdateOccurrence)         // Modifier cette occurrence / les suivantes / toutes
+	api.Post("/:event_id/occurrences/cancel", controller.CancelOccurrence) // Annuler une occurrence
+	api.Post("/:event_id/rsvp", controller.RSVPEvent)                      // Participer à un événement
+	api.Delete("/:event_id/rsvp", controller.CancelRSVP)                   // Annuler sa participation
+	api.Post("/:event_id/publish", confirmed, controller.PublishEvent)     // Publier (ou programmer) un brouillon
+	api.Post("/:event_id/unpublish", controller.UnpublishEvent)            // Repasser un événement en brouillon
+	api.Put("/:event_id/visibility", controller.SetVisibility)             // Public, non listé ou réservé aux amis
+	api.Post("/:event_id/cancel", controller.CancelEvent)                  // Annuler un événement (participants notifiés, billets remboursés)
+	api.Post("/:event_id/reschedule", controller.RescheduleEvent)          // Reporter un événement (participants notifiés)
+	api.Get("/:event_id/history", controller.GetEventHistory)              // Historique public de l'événement
+}
+
+// SetupRoutesAdminEvents configure la file de modération des événements, réservée aux administrateurs.
+func SetupRoutesAdminEvents(app *fiber.App, controller *controllers.EventController, roleLookup func(string) (models.Role, error)) {
+	api := app.Group("/api/admin/events")
+	api.Use(middlewares.JWTMiddleware)
+	api.Use(middlewares.RequireRole(roleLookup, models.RoleAdmin))
+
+	api.Get("/review", controller.GetReviewQueue)           // Événements en attente de validation
+	api.Post("/:event_id/approve", controller.ApproveEvent) // Valider un événement
+	api.Post("/:event_id/reject", controller.RejectEvent)   // Refuser un événement avec un motif
+}
+
+// SetupRoutesAdminUsers configure les routes d'administration des comptes utilisateurs.
+// Chaque action est tracée dans le journal d'audit.
+func SetupRoutesAdminUsers(app *fiber.App, controller *controllers.AdminUserController, roleLookup func(string) (models.Role, error)) {
+	api := app.Group("/api/admin/users")
+	api.Use(middlewares.JWTMiddleware)
+	api.Use(middlewares.RequireRole(roleLookup, models.RoleAdmin))
+
+	api.Get("/", controller.SearchUsers)                                 // Rechercher par nom, email, rôle ou statut
+	api.Get("/:id/activity", controller.GetUserActivity)                 // Activité récente du compte
+	api.Put("/:id/role", controller.ChangeRole)                          // Changer le rôle (ex: user → organizer)
+	api.Post("/:id/suspend", controller.SuspendUser)                     // Suspendre (until) ou bannir, avec motif
+	api.Post("/:id/reinstate", controller.ReinstateUser)                 // Lever une suspension ou un bannissement
+	api.Post("/:id/force_password_reset", controller.ForcePasswordReset) // Imposer un nouveau mot de passe
+	api.Post("/:id/unlock", controller.UnlockUser)                       // Lever le blocage de connexion après des échecs répétés
+
+	audit := app.Group("/api/admin/audit_logs")
+	audit.Use(middlewares.JWTMiddleware)
+	audit.Use(middlewares.RequireRole(roleLookup, models.RoleAdmin))
+
+	audit.Get("/", controller.GetAuditLog) // Journal des actions d'administration
+}
+
+// SetupRoutesOrganizerApplications configure les demandes de statut d'organisateur et leur traitement.
+// Les justificatifs ne sont servis qu'aux administrateurs.
+func SetupRoutesOrganizerApplications(app *fiber.App, controller *controllers.OrganizerApplicationController, confirmedLookup func(string) (bool, error), roleLookup func(string) (models.Role, error)) {
+	api := app.Group("/api/organizer_applications")
+	api.Use(middlewares.JWTMiddleware)
+	api.Use(middlewares.RequireConfirmedEmail(confirmedLookup))
+
+	api.Post("/", controller.Apply)            // Déposer une demande (multipart, avec justificatifs)
+	api.Get("/", controller.GetMyApplications) // Suivre ses demandes
+
+	admin := app.Group("/api/admin/organizer_applications")
+	admin.Use(middlewares.JWTMiddleware)
+	admin.Use(middlewares.RequireRole(roleLookup, models.RoleAdmin))
+
+	admin.Get("/", controller.GetApplications)                // Demandes par statut (en attente par défaut)
+	admin.Get("/:id/documents/:name", controller.GetDocument) // Consulter un justificatif
+	admin.Post("/:id/approve", controller.ApproveApplication) // Valider : rôle organisateur et badge vérifié
+	admin.Post("/:id/reject", controller.RejectApplication)   // Refuser avec motif
+}
+
+// SetupRoutesAPIKeys configure la gestion des clés d'API personnelles.
+// Ces routes n'acceptent que les JWT : une clé ne peut pas créer ni révoquer d'autres clés.
+func SetupRoutesAPIKeys(app *fiber.App, controller *controllers.APIKeyController, confirmedLookup func(string) (bool, error)) {
+	api := app.Group("/api/api_keys")
+	api.Use(middlewares.JWTMiddleware)
+	api.Use(middlewares.RequireConfirmedEmail(confirmedLookup))
+
+	api.Get("/", controller.GetAPIKeys)         // Lister ses clés (préfixe, scopes, dernière utilisation)
+	api.Post("/", controller.CreateAPIKey)      // Créer une clé (affichée une seule fois)
+	api.Delete("/:id", controller.RevokeAPIKey) // Révoquer une clé
+}
+
+// SetupRoutesDataExports configure les routes d'export des données personnelles (RGPD).
+func SetupRoutesDataExports(app *fiber.App, controller *controllers.DataExportController) {
+	// Téléchargement public, authentifié par la signature du lien (envoyé par email)
+	app.Get("/exports/:id/download", controller.DownloadDataExport)
+
+	api := app.Group("/api/data_exports")
+	api.Use(middlewares.JWTMiddleware)
+
+	api.Post("/", controller.RequestDataExport) // Demander un export (généré en tâche de fond)
+	api.Get("/", controller.GetDataExports)     // Lister ses exports
+	api.Get("/:id", controller.GetDataExport)   // État d'un export et lien de téléchargement signé
+}
+
+// SetupRoutesAccountDeletion configure la suppression de compte, exécutée après un délai de grâce.
+func SetupRoutesAccountDeletion(app *fiber.App, controller *controllers.AccountDeletionController) {
+	api := app.Group("/api")
+	api.Use(middlewares.JWTMiddleware)
+
+	api.Delete("/deleteMyAccount", controller.RequestDeletion)      // Programmer la suppression du compte
+	api.Get("/account/deletion", controller.GetDeletionStatus)      // Date de suppression programmée
+	api.Post("/account/deletion/cancel", controller.CancelDeletion) // Annuler pendant le délai de grâce
+}
+
+// SetupRoutesArtists configure les routes pour suivre des artistes.
+func SetupRoutesArtists(app *fiber.App, controller *controllers.ArtistController) {
+	api := app.Group("/api/artists")
+	api.Use(middlewares.JWTMiddleware)
+
+	api.Get("/followed", controller.GetFollowedArtists)  // Artistes suivis par l'utilisateur connecté
+	api.Post("/:id/follow", controller.FollowArtist)     // Suivre un artiste
+	api.Delete("/:id/follow", controller.UnfollowArtist) // Ne plus suivre un artiste
+}
+
+// SetupRoutesMusicProfile configure les routes du profil musical (genres, artistes, instruments, écoute).
+func SetupRoutesMusicProfile(app *fiber.App, controller *controllers.MusicProfileController) {
+	api := app.Group("/api")
+	api.Use(middlewares.JWTMiddleware)
+
+	api.Get("/genres", controller.GetGenres)                 // Genres pouvant être ajoutés au profil
+	api.Get("/profile/music", controller.GetMusicProfile)    // Profil musical de l'utilisateur connecté
+	api.Put("/profile/music", controller.UpdateMusicProfile) // Modifier genres, artistes, instruments et préférences d'écoute
+}
+
+// SetupRoutesCalendar configure les routes iCalendar (.ics et flux d'abonnement).
+func SetupRoutesCalendar(app *fiber.App, controller *controllers.CalendarController) {
+	// Flux public, authentifié par son jeton (les applications de calendrier n'envoient pas de JWT)
+	app.Get("/feeds/calendar/:token.ics", controller.GetUserFeed)
+
+	api := app.Group("/api")
+	api.Use(middlewares.JWTMiddleware)
+
+	api.Get("/events/:event_id/ics", controller.GetEventICS)  // Télécharger un événement au format .ics
+	api.Post("/calendar/token", controller.CreateFeedToken)   // Générer ou renouveler le jeton du flux
+	api.Delete("/calendar/token", controller.RevokeFeedToken) // Révoquer le jeton du flux
+}
+
+// SetupRoutesEventImport configure les routes d'import et d'export d'événements en masse.
+// Doit être appelée avant SetupRoutesEvents pour que /import et /export ne soient pas capturées par /:event_id.
+// L'import est réservé aux organisateurs (et administrateurs).
+func SetupRoutesEventImport(app *fiber.App, controller *controllers.EventImportController, confirmedLookup func(string) (bool, error), roleLookup func(string) (models.Role, error)) {
+	api := app.Group("/api/events")
+	api.Use(middlewares.JWTMiddleware)
+	confirmed := middlewares.RequireConfirmedEmail(confirmedLookup)
+	organizer := middlewares.RequireRole(roleLookup, models.RoleOrganizer, models.RoleAdmin)
+
+	middlewares.AllowAPIKey(fiber.MethodPost, "/api/events/import", models.ScopeEventsWrite)
+	middlewares.AllowAPIKey(fiber.MethodGet, "/api/events/import/:job_id", models.ScopeEventsRead)
+	middlewares.AllowAPIKey(fiber.MethodGet, "/api/events/export", models.ScopeEventsRead)
+
+	api.Post("/import", confirmed, organizer, controller.ImportEvents) // Importer des événements (CSV ou JSON)
+	api.Get("/import/:job_id", controller.GetImportJob)                // Suivre l'avancement d'un import
+	api.Get("/export", controller.ExportEvents)                        // Exporter ses événements (CSV ou JSON)
 }
 
 // SetupRoutesCategories configure les routes pour gérer les catégories.
@@ -59,12 +252,22 @@ func SetupFriendRoutes(app *fiber.App, friendController *controllers.FriendContr
 	api := app.Group("/api")
 	api.Use(middlewares.JWTMiddleware)
 
-	api.Post("/friend/send", friendController.SendFriendRequest)            // Envoyer une demande d'ami
-	api.Post("/friend/accept", friendController.AcceptFriendRequest)        // Accepter une demande d'ami
-	api.Post("/friend/decline", friendController.DeclineFriendRequest)      // Refuser une demande d'ami
-	api.Get("/friend/requests/:userID", friendController.GetFriendRequests) // Obtenir les demandes d'amis
-	api.Get("/friend/:userID", friendController.GetFriends)                 // Récupérer les amis d'un utilisateur
-	api.Get("/friend/search", friendController.SearchUsersByUsername)       // Rechercher des utilisateurs par nom
+	api.Post("/friend/send", friendController.SendFriendRequest)                             // Envoyer une demande d'ami
+	api.Post("/friend/accept", friendController.AcceptFriendRequest)                         // Accepter une demande d'ami
+	api.Post("/friend/decline", friendController.DeclineFriendRequest)                       // Refuser une demande d'ami
+	api.Post("/friend/cancel", friendController.CancelFriendRequest)                         // Annuler une demande envoyée encore en attente
+	api.Post("/friend/unfriend", friendController.Unfriend)                                  // Retirer un ami
+	api.Get("/friend/me", friendController.GetFriends)                                       // Mes amis
+	api.Get("/friend/me/requests", friendController.GetFriendRequests)                       // Mes demandes d'amis reçues
+	api.Get("/friend/me/requests/outgoing", friendController.GetOutgoingFriendRequests)      // Mes demandes envoyées en attente
+	api.Get("/friend/requests/:userID", friendController.GetFriendRequests)                  // Obtenir ses demandes d'amis (userID = utilisateur connecté)
+	api.Get("/friend/requests/:userID/outgoing", friendController.GetOutgoingFriendRequests) // Demandes envoyées en attente
+	api.Get("/friend/suggestions", friendController.GetFriendSuggestions)                    // Suggestions d'amis expliquées
+	api.Get("/friend/search", friendController.SearchUsersByUsername)                        // Rechercher des utilisateurs par nom (avant /friend/:userID)
+	api.Get("/friend/:userID", friendController.GetFriends)                                  // Récupérer ses amis (userID = utilisateur connecté)
+	api.Get("/blocks", friendController.GetBlockedUsers)                                     // Lister les utilisateurs bloqués
+	api.Post("/blocks/:userID", friendController.BlockUser)                                  // Bloquer un utilisateur (supprime l'amitié)
+	api.Delete("/blocks/:userID", friendController.UnblockUser)                              // Débloquer un utilisateur
 }
 
 // SetupRoutesFriendMessage configure les routes pour gérer les messages entre amis.
@@ -73,13 +276,14 @@ func SetupRoutesFriendMessage(app *fiber.App, friendChatController *controllers.
 	api.Use(middlewares.JWTMiddleware)
 
 	api.Post("/message/send", friendChatController.SendMessage)                          // Envoyer un message
-	api.Get("/message/messages/:senderID/:receiverID", friendChatController.GetMessages) // Obtenir les messages entre deux utilisateurs
+	api.Get("/message/me/:friendID", friendChatController.GetMyMessages)                 // Ma conversation avec un ami
+	api.Get("/message/messages/:senderID/:receiverID", friendChatController.GetMessages) // Obtenir une conversation dont on fait partie
 }
 
 // SetupRoutesWebSocket configure les routes pour les WebSocket.
 func SetupRoutesWebSocket(app *fiber.App, controller *controllers.WebSocketController) {
 	api := app.Group("/api")
-	api.Get("/updates", websocket.New(controller.WebSocketHandler)) // WebSocket pour les mises à jour en temps réel
+	api.Get("/updates", middlewares.WebSocketMiddleware, websocket.New(controller.WebSocketHandler)) // WebSocket authentifié pour les mises à jour de l'utilisateur
 }
 
 // SetupOpenAiRoutes configure les routes pour utiliser les services OpenAI.
@@ -93,7 +297,7 @@ func SetupOpenAiRoutes(app *fiber.App, controller *controllers.OpenAiController)
 // SetupRoutes configure toutes les routes de l'application.
 func SetupRoutes(app *fiber.App, authController *controllers.AuthController, eventController *controllers.EventController, categoryController *controllers.CategoryController, friendController *controllers.FriendController, friendChatController *controllers.FriendChatController, wsController *controllers.WebSocketController, aiController *controllers.OpenAiController) {
 	SetupRoutesAuth(app, authController)
-	SetupRoutesEvents(app, eventController)
+	SetupRoutesEvents(app, eventController, authController.AuthService.HasConfirmedEmail)
 	SetupRoutesCategories(app, categoryController)
 	SetupFriendRoutes(app, friendController)
 	SetupRoutesFriendMessage(app, friendChatController)
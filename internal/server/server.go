@@ -13,6 +13,7 @@ import (
 	"github.com/gofiber/websocket/v2"
 	"github.com/joho/godotenv"
 	"github.com/mackenzii/freemusic/internal/controllers"
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
 	"github.com/mackenzii/freemusic/internal/models"
 	"github.com/mackenzii/freemusic/internal/routes"
 	"github.com/mackenzii/freemusic/internal/services"
@@ -26,6 +27,11 @@ func Run() {
 		log.Println("No .env file found", err)
 	}
 
+	// Chargement des clés de signature des JWT
+	if err := middlewares.LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
 	// Database connection
 	db, err := storage.NewConnection()
 	if err != nil {
@@ -33,9 +39,18 @@ func Run() {
 	}
 
 	// Table migration
-	if err := db.AutoMigrate(&models.Users{}, &models.Event{}, &models.FriendRequest{}, &models.Message{}); err != nil {
+	if err := storage.MigrateEmailConfirmation(db); err != nil {
+		log.Printf("Error migrating email confirmation: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Users{}, &models.Genre{}, &models.Artist{}, &models.Event{}, &models.FriendRequest{}, &models.Message{}, &models.EventException{}, &models.Ticket{}, &models.RSVP{}, &models.ArtistFollow{}, &models.ImportJob{}, &models.EventHistory{}, &models.RefreshToken{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.Session{}, &models.APIKey{}, &models.DataExport{}, &models.AuditLog{}, &models.OrganizerApplication{}, &models.UserBlock{}); err != nil {
 		log.Printf("Error migrating database: %v", err)
 	}
+	if err := storage.MigrateMusicProfiles(db); err != nil {
+		log.Printf("Error migrating music profiles: %v", err)
+	}
+	if err := storage.MigrateFriendRequests(db); err != nil {
+		log.Printf("Error migrating friend requests: %v", err)
+	}
 
 	// Connect to Redis
 	redisClient := redis.NewClient(&redis.Options{
@@ -48,13 +63,29 @@ func Run() {
 	// Initialize services and controllers
 	imageService := services.NewImageService("./uploads")
 	emailService := services.NewEmailService()
-	authService := services.NewAuthService(db, imageService, emailService)
+	loginGuardService := services.NewLoginGuardService(redisClient)
+	authService := services.NewAuthService(db, imageService, emailService, loginGuardService)
+	middlewares.SetSessionValidator(authService.ValidateSession)
+	apiKeyService := services.NewAPIKeyService(db)
+	middlewares.SetAPIKeyAuthenticator(apiKeyService.Authenticate)
+	oauthService := services.NewOAuthService(redisClient, authService)
 	webSocketService := services.NewWebSocketService()
 	notificationService := services.NewNotificationService(db, redisClient, notificationBroadcast, webSocketService)
 	openAIService := services.NewOpenAIService()
 	friendChatService := services.NewFriendChatService(db, webSocketService)
 	categoryService := services.NewCategoryService(db)
-	eventService := services.NewEventService(db)
+	eventService := services.NewEventService(db, notificationService, emailService)
+	artistService := services.NewArtistService(db)
+	musicProfileService := services.NewMusicProfileService(db)
+	calendarService := services.NewCalendarService(db, eventService)
+	eventImportService := services.NewEventImportService(db, eventService)
+	dataExportService, err := services.NewDataExportService(db, emailService, imageService)
+	if err != nil {
+		log.Fatalf("Failed to configure data exports: %v", err)
+	}
+	accountDeletionService := services.NewAccountDeletionService(db, eventService, emailService, imageService)
+	adminUserService := services.NewAdminUserService(db, authService, emailService)
+	organizerApplicationService := services.NewOrganizerApplicationService(db, imageService, emailService)
 
 	friendService := services.NewFriendService(db, authService, webSocketService)
 	friendController := controllers.NewFriendController(friendService, notificationService)
@@ -64,10 +95,19 @@ func Run() {
 	// matchPlayersController := controllers.NewMatchPlayersController(matchPlayersService, authService, db)
 	// chatController := controllers.NewChatController(chatService)
 	openAiController := controllers.NewOpenAiController(openAIService, matchPlayersService)
-	authController := controllers.NewAuthController(authService, imageService)
+	authController := controllers.NewAuthController(authService, imageService, oauthService)
 	friendChatController := controllers.NewfriendChatController(friendChatService, friendService)
 	categoryController := controllers.NewCategoryController(categoryService, authService, db, redisClient)
 	eventController := controllers.NewEventController(eventService, authService, db, redisClient)
+	artistController := controllers.NewArtistController(artistService)
+	musicProfileController := controllers.NewMusicProfileController(musicProfileService)
+	calendarController := controllers.NewCalendarController(calendarService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	dataExportController := controllers.NewDataExportController(dataExportService)
+	accountDeletionController := controllers.NewAccountDeletionController(accountDeletionService, authService)
+	adminUserController := controllers.NewAdminUserController(adminUserService)
+	organizerApplicationController := controllers.NewOrganizerApplicationController(organizerApplicationService)
+	eventImportController := controllers.NewEventImportController(eventImportService)
 
 	// Configure Fiber app
 	app := fiber.New()
@@ -92,24 +132,32 @@ func Run() {
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Welcome to TeamUp API!")
 	})
+	routes.SetupRoutesWellKnown(app, authController)
 	routes.SetupRoutesAuth(app, authController)
 	routes.SetupRoutesCategories(app, categoryController)
 	routes.SetupOpenAiRoutes(app, openAiController)
 	routes.SetupFriendRoutes(app, friendController)
 	routes.SetupRoutesFriendMessage(app, friendChatController)
-	routes.SetupRoutesEvents(app, eventController)
+	routes.SetupRoutesEventImport(app, eventImportController, authService.HasConfirmedEmail, authService.GetUserRole)
+	routes.SetupRoutesEvents(app, eventController, authService.HasConfirmedEmail)
+	routes.SetupRoutesAdminEvents(app, eventController, authService.GetUserRole)
+	routes.SetupRoutesAdminUsers(app, adminUserController, authService.GetUserRole)
+	routes.SetupRoutesOrganizerApplications(app, organizerApplicationController, authService.HasConfirmedEmail, authService.GetUserRole)
+	routes.SetupRoutesArtists(app, artistController)
+	routes.SetupRoutesMusicProfile(app, musicProfileController)
+	routes.SetupRoutesCalendar(app, calendarController)
+	routes.SetupRoutesAPIKeys(app, apiKeyController, authService.HasConfirmedEmail)
+	routes.SetupRoutesDataExports(app, dataExportController)
+	routes.SetupRoutesAccountDeletion(app, accountDeletionController)
 
 	// Swagger route
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
-	// WebSocket route
-	app.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		webSocketService.HandleWebSocket(c)
+	// WebSocket route : authentifiée, chaque événement n'est envoyé qu'aux connexions de son destinataire
+	app.Get("/ws", middlewares.WebSocketMiddleware, websocket.New(func(c *websocket.Conn) {
+		webSocketService.HandleWebSocket(c, c.Locals("user_id").(string))
 	}))
 
-	// Start WebSocket broadcast
-	go webSocketService.StartBroadcast()
-
 	// Start listening for notifications
 	go notificationService.ListenForNotifications()
 
@@ -124,6 +172,31 @@ func Run() {
 		ticker := time.NewTicker(1 * time.Minute)
 		defer ticker.Stop()
 		for range ticker.C {
+			if n, err := eventService.PublishDueEvents(); err != nil {
+				log.Printf("Erreur lors de la publication des événements programmés : %v", err)
+			} else if n > 0 {
+				log.Printf("%d événement(s) programmé(s) publié(s)", n)
+			}
+			if n, err := eventImportService.FailStaleImports(); err != nil {
+				log.Printf("Erreur lors de la reprise des imports interrompus : %v", err)
+			} else if n > 0 {
+				log.Printf("%d import(s) d'événements interrompu(s) marqué(s) en échec", n)
+			}
+			if n, err := dataExportService.FailStaleExports(); err != nil {
+				log.Printf("Erreur lors de la reprise des exports interrompus : %v", err)
+			} else if n > 0 {
+				log.Printf("%d export(s) de données interrompu(s) marqué(s) en échec", n)
+			}
+			if n, err := dataExportService.PurgeExpiredExports(); err != nil {
+				log.Printf("Erreur lors de la suppression des exports expirés : %v", err)
+			} else if n > 0 {
+				log.Printf("%d export(s) de données expiré(s) supprimé(s)", n)
+			}
+			if n, err := accountDeletionService.ProcessDueDeletions(); err != nil {
+				log.Printf("Erreur lors de la suppression des comptes : %v", err)
+			} else if n > 0 {
+				log.Printf("%d compte(s) supprimé(s)", n)
+			}
 			// if err := matchService.UpdateMatchStatuses(); err != nil {
 			// 	log.Printf("Erreur lors de la mise à jour des statuts des matchs : %v", err)
 			// }
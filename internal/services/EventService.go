@@ -2,26 +2,64 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/mackenzii/freemusic/helpers"
 	models "github.com/mackenzii/freemusic/internal/models"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrEventNotFound        = errors.New("event not found")
+	ErrNotOrganizer         = errors.New("only the organizer can manage this event")
+	ErrInvalidEvent         = errors.New("invalid event")
+	ErrEventCancelled       = errors.New("event has been cancelled")
+	ErrOccurrenceMoveTooFar = fmt.Errorf("an occurrence cannot be moved by more than %d days", maxRescheduleShift/(24*time.Hour))
+)
+
 // EventService provides services for managing events
 type EventService struct {
-	DB *gorm.DB
+	DB                  *gorm.DB
+	NotificationService *NotificationService
+	EmailService        *EmailService
+	// ReviewRequired sends the first event of each organizer to the admin review queue
+	ReviewRequired bool
 }
 
 // NewEventService creates a new instance of EventService
-func NewEventService(db *gorm.DB) *EventService {
-	return &EventService{DB: db}
+func NewEventService(db *gorm.DB, notificationService *NotificationService, emailService *EmailService) *EventService {
+	return &EventService{
+		DB:                  db,
+		NotificationService: notificationService,
+		EmailService:        emailService,
+		ReviewRequired:      os.Getenv("EVENT_REVIEW_REQUIRED") == "true",
+	}
 }
 
 // CreateEvent creates a new event in the database
+// Validation errors wrap ErrInvalidEvent.
 func (s *EventService) CreateEvent(event *models.Event, UserID int64) error {
+	if err := s.ValidateRecurrence(event); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	if event.Visibility == "" {
+		event.Visibility = models.VisibilityPublic
+	}
+	if err := validateVisibility(event.Visibility); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
 	event.UserID = UserID
 	event.Status = "upcoming"
+	event.PublicationState = models.StateDraft
+	event.PublishAt = nil
+	event.PublishedAt = nil
 	event.CreatedAt = time.Now()
 	event.UpdatedAt = time.Now()
 
@@ -37,13 +75,70 @@ func (s *EventService) GetEventByID(eventID int) (*models.Event, error) {
 	var event models.Event
 	if err := s.DB.Where("id = ?", eventID).First(&event).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("event not found")
+			return nil, ErrEventNotFound
 		}
 		return nil, err
 	}
 	return &event, nil
 }
 
+// GetVisibleEventByID retrieves an event if viewerID is allowed to see it. Unlisted events are
+// reachable by ID; drafts and events awaiting review are only visible to their organizer.
+func (s *EventService) GetVisibleEventByID(eventID int, viewerID string) (*models.Event, error) {
+	var event models.Event
+	err := s.DB.Scopes(s.visibleTo(viewerID, true)).Where("id = ?", eventID).First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	events := []models.Event{event}
+	if err := s.withOrganizerBadges(events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// GetVisibleEvents lists the events viewerID can see, optionally filtered by a title search
+func (s *EventService) GetVisibleEvents(viewerID, query string) ([]models.Event, error) {
+	var events []models.Event
+	db := s.DB.Scopes(s.visibleTo(viewerID, false))
+	if query != "" {
+		db = db.Where("title ILIKE ?", "%"+query+"%")
+	}
+	if err := db.Order("event_time").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if err := s.withOrganizerBadges(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// visibleTo restricts a query to events visible to viewerID: their own events, plus published
+// (or scheduled and due) events that are public, unlisted when includeUnlisted, or friends-only
+// when viewerID is a friend of the organizer
+func (s *EventService) visibleTo(viewerID string, includeUnlisted bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		visibilities := []models.Visibility{models.VisibilityPublic}
+		if includeUnlisted {
+			visibilities = append(visibilities, models.VisibilityUnlisted)
+		}
+		friendIDs := s.DB.Raw(`SELECT receiver_id FROM friend_requests WHERE sender_id = ? AND status = 'accepted'
+			UNION SELECT sender_id FROM friend_requests WHERE receiver_id = ? AND status = 'accepted'`, viewerID, viewerID)
+
+		return db.Where("deleted_at IS NULL").Where(
+			s.DB.Where("organizer_id = ?", viewerID).Or(
+				s.DB.Where("publication_state = ? OR (publication_state = ? AND publish_at <= ?)",
+					models.StatePublished, models.StateScheduled, time.Now()).
+					Where(s.DB.Where("visibility IN ?", visibilities).
+						Or("visibility = ? AND organizer_id IN (?)", models.VisibilityFriends, friendIDs)),
+			),
+		)
+	}
+}
+
 // GetAllEvents retrieves all events
 func (s *EventService) GetAllEvents() ([]models.Event, error) {
 	var events []models.Event
@@ -53,8 +148,36 @@ func (s *EventService) GetAllEvents() ([]models.Event, error) {
 	return events, nil
 }
 
-// UpdateEvent updates an existing event in the database
-func (s *EventService) UpdateEvent(eventID string, event *models.Event) (*models.Event, error) {
+// UpdateEvent updates an existing event of the organizer in the database.
+// Publication fields can only be changed through the publication workflow.
+func (s *EventService) UpdateEvent(eventID string, organizerID string, event *models.Event) (*models.Event, error) {
+	id, err := strconv.Atoi(eventID)
+	if err != nil {
+		return nil, ErrEventNotFound
+	}
+	existing, err := s.getOwnedEvent(id, organizerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ValidateRecurrence(event); err != nil {
+		return nil, err
+	}
+	if event.Visibility == "" {
+		event.Visibility = existing.Visibility
+	}
+	if err := validateVisibility(event.Visibility); err != nil {
+		return nil, err
+	}
+
+	event.ID = existing.ID
+	event.UserID = existing.UserID
+	event.OrganizerID = existing.OrganizerID
+	event.CreatedAt = existing.CreatedAt
+	event.PublicationState = existing.PublicationState
+	event.PublishAt = existing.PublishAt
+	event.PublishedAt = existing.PublishedAt
+	event.ReviewNote = existing.ReviewNote
 	event.UpdatedAt = time.Now()
 
 	if err := s.DB.Save(event).Error; err != nil {
@@ -105,15 +228,230 @@ func (s *EventService) UpdateEventStatus(eventID int, status string) error {
 	return nil
 }
 
+// PublishEvent publishes a draft (or rejected) event of the organizer, immediately or at publishAt.
+// When review is required for this organizer, the event goes to the admin review queue instead.
+func (s *EventService) PublishEvent(eventID int, organizerID string, publishAt *time.Time) (*models.Event, error) {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	if event.PublicationState == models.StatePublished {
+		return nil, errors.New("event is already published")
+	}
+	if publishAt != nil && publishAt.Before(time.Now()) {
+		publishAt = nil
+	}
+	event.PublishAt = publishAt
+	event.ReviewNote = ""
+
+	needsReview, err := s.requiresReview(organizerID)
+	if err != nil {
+		return nil, err
+	}
+	if needsReview {
+		event.PublicationState = models.StatePendingReview
+	} else {
+		markPublished(event)
+	}
+
+	event.UpdatedAt = time.Now()
+	if err := s.DB.Save(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// UnpublishEvent moves an event of the organizer back to draft
+func (s *EventService) UnpublishEvent(eventID int, organizerID string) (*models.Event, error) {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	event.PublicationState = models.StateDraft
+	event.PublishAt = nil
+	event.UpdatedAt = time.Now()
+	if err := s.DB.Save(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// SetVisibility changes who can see an event of the organizer
+func (s *EventService) SetVisibility(eventID int, organizerID string, visibility models.Visibility) (*models.Event, error) {
+	if err := validateVisibility(visibility); err != nil {
+		return nil, err
+	}
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	event.Visibility = visibility
+	event.UpdatedAt = time.Now()
+	if err := s.DB.Save(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// GetReviewQueue lists the events awaiting admin review, oldest first
+func (s *EventService) GetReviewQueue() ([]models.Event, error) {
+	var events []models.Event
+	err := s.DB.Where("publication_state = ? AND deleted_at IS NULL", models.StatePendingReview).
+		Order("updated_at").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := s.withOrganizerBadges(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ApproveEvent publishes an event from the review queue (or schedules it if publish_at is ahead)
+func (s *EventService) ApproveEvent(eventID int) (*models.Event, error) {
+	event, err := s.getPendingEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	markPublished(event)
+	event.ReviewNote = ""
+	event.UpdatedAt = time.Now()
+	if err := s.DB.Save(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// RejectEvent sends an event from the review queue back to its organizer with a reason
+func (s *EventService) RejectEvent(eventID int, reason string) (*models.Event, error) {
+	event, err := s.getPendingEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	event.PublicationState = models.StateRejected
+	event.ReviewNote = reason
+	event.UpdatedAt = time.Now()
+	if err := s.DB.Save(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// PublishDueEvents publishes the scheduled events whose publication time has passed
+func (s *EventService) PublishDueEvents() (int64, error) {
+	now := time.Now()
+	result := s.DB.Model(&models.Event{}).
+		Where("publication_state = ? AND publish_at <= ?", models.StateScheduled, now).
+		Updates(map[string]interface{}{
+			"publication_state": models.StatePublished,
+			"published_at":      now,
+			"updated_at":        now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// requiresReview reports whether events of the organizer must be approved by an admin:
+// review is enabled and the organizer has never had an event published
+func (s *EventService) requiresReview(organizerID string) (bool, error) {
+	if !s.ReviewRequired {
+		return false, nil
+	}
+	var published int64
+	err := s.DB.Model(&models.Event{}).
+		Where("organizer_id = ? AND published_at IS NOT NULL", organizerID).
+		Count(&published).Error
+	if err != nil {
+		return false, err
+	}
+	return published == 0, nil
+}
+
+// getOwnedEvent retrieves an event and checks that organizerID organizes it
+func (s *EventService) getOwnedEvent(eventID int, organizerID string) (*models.Event, error) {
+	event, err := s.GetEventByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.OrganizerID == "" || event.OrganizerID != organizerID {
+		return nil, ErrNotOrganizer
+	}
+	return event, nil
+}
+
+func (s *EventService) getPendingEvent(eventID int) (*models.Event, error) {
+	event, err := s.GetEventByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.PublicationState != models.StatePendingReview {
+		return nil, errors.New("event is not awaiting review")
+	}
+	return event, nil
+}
+
+// markPublished publishes an event now, or schedules it when its publish_at is in the future
+func markPublished(event *models.Event) {
+	now := time.Now()
+	if event.PublishAt != nil && event.PublishAt.After(now) {
+		event.PublicationState = models.StateScheduled
+		event.PublishedAt = event.PublishAt
+		return
+	}
+	event.PublicationState = models.StatePublished
+	event.PublishedAt = &now
+}
+
+func validateVisibility(visibility models.Visibility) error {
+	switch visibility {
+	case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityFriends:
+		return nil
+	default:
+		return fmt.Errorf("invalid visibility: %s", visibility)
+	}
+}
+
 // GetEventsByOrganizerID retrieves events by the organizer's ID
-func (s *EventService) GetEventsByOrganizerID(organizerID int) ([]models.Event, error) {
+func (s *EventService) GetEventsByOrganizerID(organizerID string) ([]models.Event, error) {
 	var events []models.Event
 	if err := s.DB.Where("organizer_id = ? AND deleted_at IS NULL", organizerID).Find(&events).Error; err != nil {
 		return nil, err
 	}
+	if err := s.withOrganizerBadges(events); err != nil {
+		return nil, err
+	}
 	return events, nil
 }
 
+// withOrganizerBadges sets OrganizerVerified on events whose organizer holds the verified organizer badge
+func (s *EventService) withOrganizerBadges(events []models.Event) error {
+	organizerIDs := make([]string, 0, len(events))
+	for _, event := range events {
+		if event.OrganizerID != "" {
+			organizerIDs = append(organizerIDs, event.OrganizerID)
+		}
+	}
+	if len(organizerIDs) == 0 {
+		return nil
+	}
+
+	var verifiedIDs []string
+	err := s.DB.Model(&models.Users{}).
+		Where("id IN ? AND organizer_verified_at IS NOT NULL AND role IN ?", organizerIDs,
+			[]models.Role{models.RoleOrganizer, models.RoleAdmin}).
+		Pluck("id", &verifiedIDs).Error
+	if err != nil {
+		return err
+	}
+	verified := make(map[string]bool, len(verifiedIDs))
+	for _, id := range verifiedIDs {
+		verified[id] = true
+	}
+	for i := range events {
+		events[i].OrganizerVerified = verified[events[i].OrganizerID]
+	}
+	return nil
+}
+
 // SoftDeleteEvent allows an event to be marked as deleted without actually removing it from the database
 func (s *EventService) SoftDeleteEvent(eventID int) error {
 	var event models.Event
@@ -130,3 +468,693 @@ func (s *EventService) SoftDeleteEvent(eventID int) error {
 
 	return nil
 }
+
+// Edit scopes for recurring events
+const (
+	ScopeThis      = "this"
+	ScopeFollowing = "following"
+	ScopeAll       = "all"
+)
+
+// EventOccurrence is a single concrete date of an event, after applying the
+// recurrence rule and any per-occurrence exception
+type EventOccurrence struct {
+	EventID        int64     `json:"event_id"`
+	OccurrenceDate time.Time `json:"occurrence_date"` // Original start, identifies the occurrence
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Address        string    `json:"address"`
+	Cancelled      bool      `json:"cancelled"`
+	Rescheduled    bool      `json:"rescheduled"`
+
+	OrganizerVerified bool `json:"organizer_verified"`
+}
+
+// ValidateRecurrence checks the recurrence rule and time zone of an event
+func (s *EventService) ValidateRecurrence(event *models.Event) error {
+	if event.TimeZone != "" {
+		if _, err := time.LoadLocation(event.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone: %s", event.TimeZone)
+		}
+	}
+	if event.RRule == "" {
+		return nil
+	}
+	rule, err := helpers.ParseRRule(event.RRule)
+	if err != nil {
+		return err
+	}
+	event.RRule = rule.String()
+	return nil
+}
+
+// ExpandOccurrences returns the occurrences of an event starting within [from, to]
+func (s *EventService) ExpandOccurrences(event *models.Event, from, to time.Time) ([]EventOccurrence, error) {
+	start, duration, err := eventSeriesStart(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var exceptions []models.EventException
+	if err := s.DB.Where("event_id = ?", event.ID).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+	byDate := make(map[int64]models.EventException, len(exceptions))
+	for _, ex := range exceptions {
+		byDate[ex.OccurrenceDate.Unix()] = ex
+	}
+
+	var starts []time.Time
+	if event.RRule == "" {
+		if !start.Before(from) && !start.After(to) {
+			starts = append(starts, start)
+		}
+	} else {
+		rule, err := helpers.ParseRRule(event.RRule)
+		if err != nil {
+			return nil, err
+		}
+		starts = rule.Between(start, from, to)
+	}
+
+	// Occurrences rescheduled into the window from a date outside of it
+	expanded := make(map[int64]bool, len(starts))
+	for _, occStart := range starts {
+		expanded[occStart.Unix()] = true
+	}
+	for _, ex := range exceptions {
+		if ex.StartTime == nil || ex.StartTime.Before(from) || ex.StartTime.After(to) || expanded[ex.OccurrenceDate.Unix()] {
+			continue
+		}
+		occStart, err := s.resolveOccurrence(event, ex.OccurrenceDate)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, occStart)
+	}
+
+	occurrences := make([]EventOccurrence, 0, len(starts))
+	for _, occStart := range starts {
+		occ := EventOccurrence{
+			EventID:        event.ID,
+			OccurrenceDate: occStart,
+			StartTime:      occStart,
+			EndTime:        occStart.Add(duration),
+			Title:          event.Title,
+			Description:    event.Description,
+			Address:        event.Address,
+
+			OrganizerVerified: event.OrganizerVerified,
+		}
+		if ex, ok := byDate[occStart.Unix()]; ok {
+			applyException(&occ, ex)
+		}
+		if occ.StartTime.Before(from) || occ.StartTime.After(to) {
+			continue
+		}
+		occurrences = append(occurrences, occ)
+	}
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTime.Before(occurrences[j].StartTime)
+	})
+	return occurrences, nil
+}
+
+// GetOccurrences returns the occurrences of a single event visible to viewerID within [from, to]
+func (s *EventService) GetOccurrences(eventID int, viewerID string, from, to time.Time) ([]EventOccurrence, error) {
+	event, err := s.GetVisibleEventByID(eventID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExpandOccurrences(event, from, to)
+}
+
+// GetAllOccurrences returns the occurrences of every event visible to viewerID within [from, to], sorted by start time
+func (s *EventService) GetAllOccurrences(viewerID string, from, to time.Time) ([]EventOccurrence, error) {
+	events, err := s.GetVisibleEvents(viewerID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []EventOccurrence
+	for i := range events {
+		occ, err := s.ExpandOccurrences(&events[i], from, to)
+		if err != nil {
+			log.Printf("Skipping event %d with invalid recurrence: %v", events[i].ID, err)
+			continue
+		}
+		occurrences = append(occurrences, occ...)
+	}
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTime.Before(occurrences[j].StartTime)
+	})
+	return occurrences, nil
+}
+
+// CancelOccurrence cancels a single occurrence of a recurring event of organizerID
+func (s *EventService) CancelOccurrence(eventID int, organizerID string, occurrence time.Time) error {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return err
+	}
+	occurrence, err = s.resolveOccurrence(event, occurrence)
+	if err != nil {
+		return err
+	}
+
+	exception, err := s.findOrNewException(s.DB, event.ID, occurrence)
+	if err != nil {
+		return err
+	}
+	exception.Cancelled = true
+	return s.DB.Save(exception).Error
+}
+
+// UpdateOccurrence edits a recurring event of organizerID from a given occurrence.
+//
+// scope "this" only changes the given occurrence, "following" splits the series so the
+// changes apply from this occurrence onwards, and "all" changes the whole series.
+// Zero-valued fields of changes are left untouched. If changes.EventTime is set, the
+// occurrence is moved to that time (and following/all occurrences are shifted by the same delta).
+// A single occurrence cannot be moved by more than maxRescheduleShift.
+func (s *EventService) UpdateOccurrence(eventID int, organizerID string, occurrence time.Time, scope string, changes *models.Event) (*models.Event, error) {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	occurrence, err = s.resolveOccurrence(event, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	var shift time.Duration
+	if !changes.EventTime.IsZero() {
+		shift = changes.EventTime.Sub(occurrence)
+	}
+
+	switch scope {
+	case ScopeThis:
+		return event, s.updateSingleOccurrence(event, occurrence, shift, changes)
+	case ScopeAll:
+		applyEventChanges(event, changes, shift)
+		if err := s.ValidateRecurrence(event); err != nil {
+			return nil, err
+		}
+		event.UpdatedAt = time.Now()
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(event).Error; err != nil {
+				return err
+			}
+			return shiftExceptions(tx, event.ID, shift)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	case ScopeFollowing:
+		start, _, err := eventSeriesStart(event)
+		if err != nil {
+			return nil, err
+		}
+		if event.RRule == "" || occurrence.Equal(start) {
+			return s.UpdateOccurrence(eventID, organizerID, occurrence, ScopeAll, changes)
+		}
+		return s.splitSeries(event, occurrence, shift, changes)
+	default:
+		return nil, fmt.Errorf("invalid scope: %s", scope)
+	}
+}
+
+// updateSingleOccurrence stores overrides for one occurrence as an exception
+func (s *EventService) updateSingleOccurrence(event *models.Event, occurrence time.Time, shift time.Duration, changes *models.Event) error {
+	if shift > maxRescheduleShift || shift < -maxRescheduleShift {
+		return ErrOccurrenceMoveTooFar
+	}
+
+	_, duration, err := eventSeriesStart(event)
+	if err != nil {
+		return err
+	}
+
+	exception, err := s.findOrNewException(s.DB, event.ID, occurrence)
+	if err != nil {
+		return err
+	}
+	if shift != 0 {
+		start := occurrence.Add(shift)
+		end := start.Add(duration)
+		if !changes.EndTime.IsZero() {
+			end = changes.EndTime
+		}
+		exception.StartTime = &start
+		exception.EndTime = &end
+	} else if !changes.EndTime.IsZero() {
+		end := changes.EndTime
+		exception.EndTime = &end
+	}
+	if changes.Title != "" {
+		exception.Title = changes.Title
+	}
+	if changes.Description != "" {
+		exception.Description = changes.Description
+	}
+	if changes.Address != "" {
+		exception.Address = changes.Address
+	}
+	exception.Cancelled = false
+	return s.DB.Save(exception).Error
+}
+
+// splitSeries ends the original series just before occurrence and creates a new series,
+// starting at occurrence, that carries the changes and the exceptions of later occurrences
+func (s *EventService) splitSeries(event *models.Event, occurrence time.Time, shift time.Duration, changes *models.Event) (*models.Event, error) {
+	rule, err := helpers.ParseRRule(event.RRule)
+	if err != nil {
+		return nil, err
+	}
+	start, duration, err := eventSeriesStart(event)
+	if err != nil {
+		return nil, err
+	}
+
+	// The new series continues the same rule; a COUNT is shared between both halves
+	newRule := *rule
+	if rule.Count > 0 {
+		before := rule.CountBefore(start, occurrence)
+		rule.Count = before
+		newRule.Count -= before
+	} else {
+		until := occurrence.Add(-time.Second)
+		rule.Until = &until
+	}
+
+	parentID := event.ID
+	if event.ParentEventID != nil {
+		parentID = *event.ParentEventID
+	}
+
+	next := *event
+	next.ID = 0
+	next.ParentEventID = &parentID
+	next.EventDate = occurrence
+	next.EventTime = occurrence
+	next.EndTime = occurrence.Add(duration)
+	next.RRule = newRule.String()
+	next.CreatedAt = time.Now()
+	next.UpdatedAt = time.Now()
+	applyEventChanges(&next, changes, shift)
+	if err := s.ValidateRecurrence(&next); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		event.RRule = rule.String()
+		event.UpdatedAt = time.Now()
+		if err := tx.Save(event).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+		// Exceptions of later occurrences follow the new series, shifted like their occurrence
+		var exceptions []models.EventException
+		if err := tx.Where("event_id = ? AND occurrence_date >= ?", event.ID, occurrence).Find(&exceptions).Error; err != nil {
+			return err
+		}
+		for i := range exceptions {
+			exceptions[i].EventID = next.ID
+			exceptions[i].OccurrenceDate = exceptions[i].OccurrenceDate.Add(shift)
+			if err := tx.Save(&exceptions[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// resolveOccurrence checks that occurrence is a date of the event and returns it in the event's time zone
+func (s *EventService) resolveOccurrence(event *models.Event, occurrence time.Time) (time.Time, error) {
+	start, _, err := eventSeriesStart(event)
+	if err != nil {
+		return time.Time{}, err
+	}
+	occurrence = occurrence.In(start.Location())
+
+	if event.RRule == "" {
+		if !occurrence.Equal(start) {
+			return time.Time{}, errors.New("occurrence not found")
+		}
+		return occurrence, nil
+	}
+	rule, err := helpers.ParseRRule(event.RRule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !rule.Includes(start, occurrence) {
+		return time.Time{}, errors.New("occurrence not found")
+	}
+	return occurrence, nil
+}
+
+// shiftExceptions moves the exceptions of an event by shift so that they keep matching their occurrence
+// once the whole series is moved. Rows are updated starting from the direction of the move, to avoid
+// transient conflicts on the (event_id, occurrence_date) unique index.
+func shiftExceptions(tx *gorm.DB, eventID int64, shift time.Duration) error {
+	if shift == 0 {
+		return nil
+	}
+	order := "occurrence_date DESC"
+	if shift < 0 {
+		order = "occurrence_date ASC"
+	}
+
+	var exceptions []models.EventException
+	if err := tx.Where("event_id = ?", eventID).Order(order).Find(&exceptions).Error; err != nil {
+		return err
+	}
+	for i := range exceptions {
+		if err := tx.Model(&exceptions[i]).Update("occurrence_date", exceptions[i].OccurrenceDate.Add(shift)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EventService) findOrNewException(db *gorm.DB, eventID int64, occurrence time.Time) (*models.EventException, error) {
+	var exception models.EventException
+	err := db.Where("event_id = ? AND occurrence_date = ?", eventID, occurrence).First(&exception).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.EventException{EventID: eventID, OccurrenceDate: occurrence}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exception, nil
+}
+
+// maxRescheduleShift bounds how far a single occurrence can be moved from its original date
+const maxRescheduleShift = 31 * 24 * time.Hour
+
+// eventSeriesStart returns the first start of the event in its time zone and the duration of each occurrence
+func eventSeriesStart(event *models.Event) (time.Time, time.Duration, error) {
+	start := event.EventTime
+	if start.IsZero() {
+		start = event.EventDate
+	}
+	if event.TimeZone != "" {
+		loc, err := time.LoadLocation(event.TimeZone)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid time zone: %s", event.TimeZone)
+		}
+		start = start.In(loc)
+	}
+
+	var duration time.Duration
+	if !event.EndTime.IsZero() && event.EndTime.After(start) {
+		duration = event.EndTime.Sub(start)
+	}
+	return start, duration, nil
+}
+
+func applyException(occ *EventOccurrence, ex models.EventException) {
+	occ.Cancelled = ex.Cancelled
+	if ex.StartTime != nil {
+		occ.StartTime = *ex.StartTime
+		occ.Rescheduled = true
+	}
+	if ex.EndTime != nil {
+		occ.EndTime = *ex.EndTime
+	}
+	if ex.Title != "" {
+		occ.Title = ex.Title
+	}
+	if ex.Description != "" {
+		occ.Description = ex.Description
+	}
+	if ex.Address != "" {
+		occ.Address = ex.Address
+	}
+}
+
+// applyEventChanges copies the non-zero fields of changes onto event and shifts its times
+func applyEventChanges(event *models.Event, changes *models.Event, shift time.Duration) {
+	if changes.Title != "" {
+		event.Title = changes.Title
+	}
+	if changes.Description != "" {
+		event.Description = changes.Description
+	}
+	if changes.Address != "" {
+		event.Address = changes.Address
+	}
+	if changes.RRule != "" {
+		event.RRule = changes.RRule
+	}
+	if changes.TimeZone != "" {
+		event.TimeZone = changes.TimeZone
+	}
+	if shift != 0 {
+		event.EventDate = event.EventDate.Add(shift)
+		event.EventTime = event.EventTime.Add(shift)
+		event.EndTime = event.EndTime.Add(shift)
+	}
+	if !changes.EndTime.IsZero() {
+		event.EndTime = changes.EndTime
+	}
+}
+
+// RSVPEvent records (or updates) the participation of a user to an event
+func (s *EventService) RSVPEvent(eventID int, userID string, status models.RSVPStatus) (*models.RSVP, error) {
+	if status != models.RSVPGoing && status != models.RSVPInterested {
+		return nil, errors.New("invalid RSVP status")
+	}
+	// An event the user cannot see cannot be joined either
+	event, err := s.GetVisibleEventByID(eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Attendees are notified when an event is cancelled: joining it afterwards would go unnoticed
+	if event.Status == models.Cancelled {
+		return nil, ErrEventCancelled
+	}
+
+	var rsvp models.RSVP
+	err = s.DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&rsvp).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	rsvp.EventID = int64(eventID)
+	rsvp.UserID = userID
+	rsvp.Status = status
+	if err := s.DB.Save(&rsvp).Error; err != nil {
+		return nil, err
+	}
+	return &rsvp, nil
+}
+
+// CancelRSVP removes the participation of a user to an event
+func (s *EventService) CancelRSVP(eventID int, userID string) error {
+	return s.DB.Where("event_id = ? AND user_id = ?", eventID, userID).Delete(&models.RSVP{}).Error
+}
+
+// CancelEvent cancels an event of the organizer, records the reason in its history,
+// requests refunds for paid tickets and notifies every attendee
+func (s *EventService) CancelEvent(eventID int, organizerID, reason string) (*models.Event, error) {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Status == models.Cancelled {
+		return nil, errors.New("event is already cancelled")
+	}
+	if reason == "" {
+		return nil, errors.New("a reason is required")
+	}
+
+	var refunded int64
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		event.Status = models.Cancelled
+		event.UpdatedAt = time.Now()
+		if err := tx.Save(event).Error; err != nil {
+			return err
+		}
+
+		history := models.EventHistory{
+			EventID:       event.ID,
+			Action:        models.ActionCancelled,
+			Reason:        reason,
+			PreviousStart: timePtr(event.EventTime),
+			PreviousEnd:   timePtr(event.EndTime),
+			ActorID:       organizerID,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+
+		refunded, err = refundEventTickets(tx, event.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Event %d cancelled, %d ticket refund(s) requested", event.ID, refunded)
+
+	message := fmt.Sprintf("L'événement \"%s\" du %s est annulé. Motif : %s",
+		event.Title, event.EventTime.Format("02/01/2006 15:04"), reason)
+	if refunded > 0 {
+		message += ". Les billets payés seront remboursés."
+	}
+	go s.notifyAttendees(event, "Événement annulé", message)
+
+	return event, nil
+}
+
+// RescheduleEvent moves an event of the organizer to a new start (and optionally end) time,
+// records the change in its history and notifies every attendee
+func (s *EventService) RescheduleEvent(eventID int, organizerID string, newStart time.Time, newEnd *time.Time, reason string) (*models.Event, error) {
+	event, err := s.getOwnedEvent(eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Status == models.Cancelled {
+		return nil, errors.New("a cancelled event cannot be rescheduled")
+	}
+	if newStart.IsZero() {
+		return nil, errors.New("a new start time is required")
+	}
+
+	previousStart, previousEnd := event.EventTime, event.EndTime
+	start, duration, err := eventSeriesStart(event)
+	if err != nil {
+		return nil, err
+	}
+	shift := newStart.Sub(start)
+
+	event.EventDate = event.EventDate.Add(shift)
+	event.EventTime = newStart
+	event.EndTime = newStart.Add(duration)
+	if newEnd != nil {
+		if !newEnd.After(newStart) {
+			return nil, errors.New("end time must be after start time")
+		}
+		event.EndTime = *newEnd
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		event.UpdatedAt = time.Now()
+		if err := tx.Save(event).Error; err != nil {
+			return err
+		}
+		// Exceptions of a recurring event follow their occurrence
+		if err := shiftExceptions(tx, event.ID, shift); err != nil {
+			return err
+		}
+		history := models.EventHistory{
+			EventID:       event.ID,
+			Action:        models.ActionRescheduled,
+			Reason:        reason,
+			PreviousStart: timePtr(previousStart),
+			PreviousEnd:   timePtr(previousEnd),
+			NewStart:      timePtr(event.EventTime),
+			NewEnd:        timePtr(event.EndTime),
+			ActorID:       organizerID,
+		}
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("L'événement \"%s\" est déplacé du %s au %s.",
+		event.Title, previousStart.Format("02/01/2006 15:04"), event.EventTime.Format("02/01/2006 15:04"))
+	if reason != "" {
+		message += " Motif : " + reason
+	}
+	go s.notifyAttendees(event, "Événement reporté", message)
+
+	return event, nil
+}
+
+// GetEventHistory returns the public history of an event visible to viewerID, most recent first
+func (s *EventService) GetEventHistory(eventID int, viewerID string) ([]models.EventHistory, error) {
+	if _, err := s.GetVisibleEventByID(eventID, viewerID); err != nil {
+		return nil, err
+	}
+
+	var history []models.EventHistory
+	if err := s.DB.Where("event_id = ?", eventID).Order("created_at desc").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetAttendees returns the users who RSVP'd to an event or hold a ticket for it
+func (s *EventService) GetAttendees(eventID int64) ([]models.Users, error) {
+	var users []models.Users
+	err := s.DB.Where("id IN (?) OR id IN (?)",
+		s.DB.Model(&models.RSVP{}).Select("user_id").Where("event_id = ?", eventID),
+		s.DB.Model(&models.Ticket{}).Select("user_id").Where("event_id = ?", eventID),
+	).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// notifyAttendees sends a WebSocket notification, a push notification and an email to every
+// attendee of an event. It is used for transactional notices (cancellation, rescheduling, refunds),
+// which reach every attendee even if they blocked the organizer or were blocked by them.
+// Failures are logged and do not stop the fan-out.
+func (s *EventService) notifyAttendees(event *models.Event, title, message string) {
+	attendees, err := s.GetAttendees(event.ID)
+	if err != nil {
+		log.Printf("Failed to load attendees of event %d: %v", event.ID, err)
+		return
+	}
+
+	for _, user := range attendees {
+		if s.NotificationService != nil {
+			if err := s.NotificationService.SendWebSocketNotification(user.ID, title, message); err != nil {
+				log.Printf("Failed to notify user %s about event %d: %v", user.ID, event.ID, err)
+			}
+		}
+		if s.EmailService != nil && user.Email != "" {
+			err := s.EmailService.SendNotificationEmail(NotificationEmail{
+				ToEmail:    user.Email,
+				Subject:    title + " : " + event.Title,
+				Heading:    title,
+				Paragraphs: []string{message},
+			})
+			if err != nil {
+				log.Printf("Failed to email user %s about event %d: %v", user.ID, event.ID, err)
+			}
+		}
+	}
+	log.Printf("Notified %d attendee(s) of event %d", len(attendees), event.ID)
+}
+
+// refundEventTickets requests a refund for every paid ticket of an event.
+// Tickets are marked refund_pending; the payment provider settles them asynchronously.
+func refundEventTickets(tx *gorm.DB, eventID int64) (int64, error) {
+	now := time.Now()
+	result := tx.Model(&models.Ticket{}).
+		Where("event_id = ? AND payment_status = ? AND price_cents > 0", eventID, models.PaymentPaid).
+		Updates(map[string]interface{}{
+			"payment_status":      models.PaymentRefundPending,
+			"refund_requested_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
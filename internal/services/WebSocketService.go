@@ -3,32 +3,49 @@ package services
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
 
+// webSocketWriteTimeout bounds how long a slow or stalled client can hold up a delivery
+const webSocketWriteTimeout = 10 * time.Second
+
+// WebSocketService keeps the open WebSocket connections of each authenticated user,
+// so that an event is only delivered to the connections of its recipient.
 type WebSocketService struct {
-	connections map[*websocket.Conn]bool
-	broadcast   chan []byte
+	connections map[string]map[*webSocketClient]bool
 	mutex       sync.Mutex
 }
 
+// webSocketClient is an open connection. Writes are serialized per connection, outside the service mutex.
+type webSocketClient struct {
+	conn  *websocket.Conn
+	mutex sync.Mutex
+}
+
 func NewWebSocketService() *WebSocketService {
 	return &WebSocketService{
-		connections: make(map[*websocket.Conn]bool),
-		broadcast:   make(chan []byte),
+		connections: make(map[string]map[*webSocketClient]bool),
 	}
 }
 
-func (s *WebSocketService) HandleWebSocket(c *websocket.Conn) {
+// HandleWebSocket registers the connection of userID until it is closed.
+// The connection must have been authenticated during the upgrade (see middlewares.WebSocketMiddleware).
+// Messages sent by the client are ignored: the channel only carries server events.
+func (s *WebSocketService) HandleWebSocket(c *websocket.Conn, userID string) {
 	log.Println("Handling new WebSocket connection")
+	client := &webSocketClient{conn: c}
 	s.mutex.Lock()
-	s.connections[c] = true
+	if s.connections[userID] == nil {
+		s.connections[userID] = make(map[*webSocketClient]bool)
+	}
+	s.connections[userID][client] = true
 	s.mutex.Unlock()
 
 	defer func() {
 		s.mutex.Lock()
-		delete(s.connections, c)
+		s.remove(userID, client)
 		s.mutex.Unlock()
 		if err := c.Close(); err != nil {
 			log.Println("Error closing WebSocket connection:", err)
@@ -37,28 +54,44 @@ func (s *WebSocketService) HandleWebSocket(c *websocket.Conn) {
 	}()
 
 	for {
-		_, msg, err := c.ReadMessage()
-		if err != nil {
+		if _, _, err := c.ReadMessage(); err != nil {
 			log.Println("Error reading WebSocket message:", err)
 			break
 		}
-		log.Printf("Received message: %s", msg)
-		s.broadcast <- msg
 	}
 }
 
-func (s *WebSocketService) StartBroadcast() {
-	for {
-		msg := <-s.broadcast
-		log.Printf("Broadcasting message: %s", msg)
-		s.mutex.Lock()
-		for conn := range s.connections {
-			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-				log.Println("Error writing WebSocket message:", err)
-				conn.Close()
-				delete(s.connections, conn)
-			}
+// SendToUser writes msg to every open connection of userID. It does nothing if the user is offline.
+// A connection that cannot be written to within webSocketWriteTimeout is closed; HandleWebSocket then forgets it.
+func (s *WebSocketService) SendToUser(userID string, msg []byte) {
+	s.mutex.Lock()
+	clients := make([]*webSocketClient, 0, len(s.connections[userID]))
+	for client := range s.connections[userID] {
+		clients = append(clients, client)
+	}
+	s.mutex.Unlock()
+
+	for _, client := range clients {
+		if err := client.write(msg); err != nil {
+			log.Println("Error writing WebSocket message:", err)
+			client.conn.Close()
 		}
-		s.mutex.Unlock()
+	}
+}
+
+func (c *webSocketClient) write(msg []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// remove forgets a connection of userID. The caller must hold the mutex.
+func (s *WebSocketService) remove(userID string, client *webSocketClient) {
+	delete(s.connections[userID], client)
+	if len(s.connections[userID]) == 0 {
+		delete(s.connections, userID)
 	}
 }
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeletionAlreadyScheduled = errors.New("account deletion is already scheduled")
+	ErrDeletionNotScheduled     = errors.New("no account deletion is scheduled")
+	ErrInvalidTransferTarget    = errors.New("events can only be transferred to a friend with an active account")
+)
+
+// defaultDeletionGracePeriod s'applique lorsque ACCOUNT_DELETION_GRACE_DAYS n'est pas défini
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// deletedAccountReason est le motif d'annulation communiqué aux participants
+const deletedAccountReason = "L'organisateur a supprimé son compte"
+
+// AccountDeletionService gère la suppression des comptes : demande, délai de grâce, puis anonymisation.
+//
+// À l'échéance, les données personnelles sont supprimées (messages, amitiés, participations, sessions,
+// clés, exports, photo de profil) et la ligne users est anonymisée plutôt que supprimée, afin de
+// conserver les billets (comptabilité) et l'historique des événements passés.
+type AccountDeletionService struct {
+	DB           *gorm.DB
+	EventService *EventService
+	EmailService *EmailService
+	ImageService *ImageService
+	GracePeriod  time.Duration
+}
+
+// NewAccountDeletionService crée une nouvelle instance de AccountDeletionService.
+// Le délai de grâce est lu dans ACCOUNT_DELETION_GRACE_DAYS (30 jours par défaut, 0 pour une suppression immédiate).
+func NewAccountDeletionService(db *gorm.DB, eventService *EventService, emailService *EmailService, imageService *ImageService) *AccountDeletionService {
+	gracePeriod := defaultDeletionGracePeriod
+	if days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")); err == nil && days >= 0 {
+		gracePeriod = time.Duration(days) * 24 * time.Hour
+	}
+
+	return &AccountDeletionService{
+		DB:           db,
+		EventService: eventService,
+		EmailService: emailService,
+		ImageService: imageService,
+		GracePeriod:  gracePeriod,
+	}
+}
+
+// RequestDeletion programme la suppression du compte à la fin du délai de grâce.
+// transferTo (optionnel) désigne un ami qui reprendra les événements publiés à venir ; sans lui ils sont annulés.
+func (s *AccountDeletionService) RequestDeletion(userID, transferTo string) (*models.Users, error) {
+	if transferTo != "" {
+		if err := s.checkTransferTarget(userID, transferTo); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	scheduledAt := now.Add(s.GracePeriod)
+	res := s.DB.Model(&models.Users{}).
+		Where("id = ? AND deletion_scheduled_at IS NULL AND deleted_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"deletion_requested_at": now,
+			"deletion_scheduled_at": scheduledAt,
+			"deletion_transfer_to":  transferTo,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrDeletionAlreadyScheduled
+	}
+
+	var user models.Users
+	if err := s.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := s.EmailService.SendNotificationEmail(NotificationEmail{
+			ToEmail: user.Email,
+			Subject: "Suppression de votre compte",
+			Heading: "Votre compte sera supprimé",
+			Paragraphs: []string{
+				"Vous avez demandé la suppression de votre compte.",
+				"Elle sera effective le " + scheduledAt.Format("02/01/2006 à 15:04") + ".",
+				"D'ici là, vous pouvez l'annuler en vous connectant à l'application.",
+				"Si vous n'êtes pas à l'origine de cette demande, connectez-vous pour l'annuler puis changez votre mot de passe.",
+			},
+		}); err != nil {
+			log.Printf("Error sending deletion notice: %v", err)
+		}
+	}()
+
+	return &user, nil
+}
+
+// CancelDeletion annule une suppression programmée, tant qu'elle n'a pas commencé
+func (s *AccountDeletionService) CancelDeletion(userID string) error {
+	res := s.DB.Model(&models.Users{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"deletion_requested_at": nil,
+			"deletion_scheduled_at": nil,
+			"deletion_transfer_to":  "",
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeletionNotScheduled
+	}
+	return nil
+}
+
+// checkTransferTarget vérifie que le destinataire des événements est un ami dont le compte est actif
+func (s *AccountDeletionService) checkTransferTarget(userID, transferTo string) error {
+	if transferTo == userID {
+		return ErrInvalidTransferTarget
+	}
+
+	var target models.Users
+	if err := s.DB.Select("id").
+		Where("id = ? AND deleted_at IS NULL AND deletion_scheduled_at IS NULL", transferTo).
+		First(&target).Error; err != nil {
+		return ErrInvalidTransferTarget
+	}
+
+	var friends int64
+	if err := s.DB.Model(&models.FriendRequest{}).
+		Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND status = ?",
+			userID, transferTo, transferTo, userID, "accepted").
+		Count(&friends).Error; err != nil {
+		return err
+	}
+	if friends == 0 {
+		return ErrInvalidTransferTarget
+	}
+	return nil
+}
+
+// ProcessDueDeletions exécute les suppressions dont le délai de grâce est écoulé et retourne le nombre de comptes supprimés
+func (s *AccountDeletionService) ProcessDueDeletions() (int, error) {
+	var userIDs []string
+	if err := s.DB.Model(&models.Users{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", time.Now()).
+		Pluck("id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, userID := range userIDs {
+		done, err := s.DeleteUserAndRelatedData(userID)
+		if err != nil {
+			// La suppression reprendra au prochain passage : chaque étape peut être rejouée
+			log.Printf("Failed to delete account %s: %v", userID, err)
+			continue
+		}
+		// Les suppressions annulées entre-temps ne sont pas comptées
+		if done {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DeleteUserAndRelatedData supprime les données d'un compte dont la suppression est échue.
+//
+// Le compte est d'abord verrouillé (deleted_at), ce qui empêche l'annulation et invalide ses tokens ;
+// les événements sont ensuite transférés ou annulés, puis les données personnelles supprimées
+// et le compte anonymisé dans une même transaction.
+// Retourne false si la suppression a été annulée entre-temps ou était déjà terminée.
+func (s *AccountDeletionService) DeleteUserAndRelatedData(userID string) (bool, error) {
+	now := time.Now()
+	if err := s.DB.Model(&models.Users{}).
+		Where("id = ? AND deletion_scheduled_at <= ? AND deleted_at IS NULL", userID, now).
+		Update("deleted_at", now).Error; err != nil {
+		return false, err
+	}
+
+	var user models.Users
+	if err := s.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return false, err
+	}
+	// Suppression annulée avant le verrouillage, ou déjà terminée
+	if user.DeletionScheduledAt == nil || user.DeletedAt == nil {
+		return false, nil
+	}
+
+	if err := s.handleOrganizedEvents(user); err != nil {
+		return false, err
+	}
+
+	var exports []models.DataExport
+	if err := s.DB.Where("user_id = ?", userID).Find(&exports).Error; err != nil {
+		return false, err
+	}
+	var applications []models.OrganizerApplication
+	if err := s.DB.Where("user_id = ?", userID).Find(&applications).Error; err != nil {
+		return false, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Conversations et relations : supprimées dans les deux sens
+		if err := tx.Where("sender_id = ? OR receiver_id = ?", userID, userID).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("sender_id = ? OR receiver_id = ?", userID, userID).Delete(&models.FriendRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blocker_id = ? OR blocked_id = ?", userID, userID).Delete(&models.UserBlock{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.RSVP{}, &models.ArtistFollow{}, &models.Session{}, &models.RefreshToken{},
+			&models.APIKey{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.DataExport{},
+			&models.OrganizerApplication{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		// Profil musical : seules les liaisons sont supprimées, les genres et artistes restent
+		if err := tx.Exec("DELETE FROM user_favorite_genres WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_favorite_artists WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		// Les événements passés restent consultables, sans lien avec le compte supprimé
+		if err := tx.Model(&models.Event{}).Where("organizer_id = ?", userID).Update("organizer_id", "").Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Users{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":                  "Utilisateur supprimé",
+			"email":                     "deleted+" + strings.ToLower(userID) + "@deleted.invalid",
+			"password_hash":             "",
+			"passwordless":              true,
+			"birth_date":                nil,
+			"profile_photo":             "",
+			"location":                  "",
+			"latitude":                  0,
+			"longitude":                 0,
+			"instruments":               "",
+			"listening_preferences":     "",
+			"bio":                       "",
+			"is_confirmed":              false,
+			"confirmation_token":        "",
+			"token_expires_at":          nil,
+			"pending_email":             "",
+			"email_change_token_hash":   "",
+			"password_reset_token_hash": "",
+			"totp_secret":               "",
+			"totp_enabled":              false,
+			"role":                      models.RoleUser,
+			"organizer_verified_at":     nil,
+			"fcm_token":                 "",
+			"calendar_token_hash":       "",
+			"tokens_revoked_at":         now,
+			"deletion_scheduled_at":     nil,
+			"deletion_transfer_to":      "",
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Fichiers : supprimés une fois les données effacées, un échec est seulement journalisé
+	s.removeUpload(user.ProfilePhoto)
+	for _, application := range applications {
+		for _, document := range application.DocumentList() {
+			s.removeUpload(document)
+		}
+	}
+	for _, export := range exports {
+		if export.FilePath != "" {
+			if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Failed to remove data export %s: %v", export.ID, err)
+			}
+		}
+	}
+
+	go func() {
+		if err := s.EmailService.SendNotificationEmail(NotificationEmail{
+			ToEmail: user.Email,
+			Subject: "Votre compte a été supprimé",
+			Heading: "Votre compte a été supprimé",
+			Paragraphs: []string{
+				"Conformément à votre demande, votre compte et vos données personnelles ont été supprimés.",
+				"Les billets achetés sont conservés de manière anonyme pour nos obligations comptables.",
+			},
+		}); err != nil {
+			log.Printf("Error sending deletion confirmation: %v", err)
+		}
+	}()
+
+	return true, nil
+}
+
+// handleOrganizedEvents traite les événements organisés par le compte supprimé :
+// les événements publiés à venir sont transférés à l'ami désigné ou annulés (participants prévenus,
+// billets remboursés), et ceux qui n'ont jamais été publiés sont supprimés.
+func (s *AccountDeletionService) handleOrganizedEvents(user models.Users) error {
+	transferTo := user.DeletionTransferTo
+	if transferTo != "" {
+		var target models.Users
+		if err := s.DB.Select("id").Where("id = ? AND deleted_at IS NULL", transferTo).First(&target).Error; err != nil {
+			log.Printf("Transfer target %s of account %s is no longer available, events will be cancelled", transferTo, user.ID)
+			transferTo = ""
+		}
+	}
+
+	if err := s.DB.Where("organizer_id = ? AND publication_state <> ?", user.ID, models.StatePublished).
+		Delete(&models.Event{}).Error; err != nil {
+		return err
+	}
+
+	var upcoming []models.Event
+	if err := s.DB.Where("organizer_id = ? AND status <> ? AND (event_time > ? OR rrule <> '')",
+		user.ID, models.Cancelled, time.Now()).Find(&upcoming).Error; err != nil {
+		return err
+	}
+
+	for _, event := range upcoming {
+		if transferTo != "" {
+			err := s.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Event{}).Where("id = ?", event.ID).Update("organizer_id", transferTo).Error; err != nil {
+					return err
+				}
+				return tx.Create(&models.EventHistory{
+					EventID: event.ID,
+					Action:  models.ActionTransferred,
+					Reason:  deletedAccountReason,
+					ActorID: user.ID,
+				}).Error
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := s.EventService.CancelEvent(int(event.ID), user.ID, deletedAccountReason); err != nil {
+			return fmt.Errorf("cancel event %d: %w", event.ID, err)
+		}
+	}
+	return nil
+}
+
+// removeUpload supprime un fichier du dossier de téléversement (les URL externes sont ignorées)
+func (s *AccountDeletionService) removeUpload(name string) {
+	if name == "" || strings.Contains(name, "://") || filepath.Base(name) != name {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.ImageService.UploadDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove upload %s: %v", name, err)
+	}
+}
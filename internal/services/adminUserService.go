@@ -0,0 +1,464 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	adminSearchDefaultLimit = 20
+	adminSearchMaxLimit     = 100
+	activityListLimit       = 10
+	activityWindow          = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRole            = errors.New("role must be one of: user, organizer, admin")
+	ErrCannotModerateSelf     = errors.New("administrators cannot moderate their own account")
+	ErrCannotModerateAdmin    = errors.New("administrators must be demoted before being suspended")
+	ErrReasonRequired         = errors.New("a reason is required")
+	ErrInvalidSuspensionUntil = errors.New("suspension end must be in the future")
+	ErrNotSuspended           = errors.New("account is not suspended")
+	ErrInvalidUserStatus      = errors.New("status must be one of: active, suspended, deleted")
+)
+
+// AuditActor identifie l'administrateur à l'origine d'une action tracée
+type AuditActor struct {
+	ID string
+	IP string
+}
+
+// AdminUser est la vue d'un compte destinée aux administrateurs
+type AdminUser struct {
+	ID                    string      `json:"id"`
+	Username              string      `json:"username"`
+	Email                 string      `json:"email"`
+	Role                  models.Role `json:"role"`
+	IsConfirmed           bool        `json:"is_confirmed"`
+	TOTPEnabled           bool        `json:"totp_enabled"`
+	Suspended             bool        `json:"suspended"`
+	SuspendedAt           *time.Time  `json:"suspended_at,omitempty"`
+	SuspendedUntil        *time.Time  `json:"suspended_until,omitempty"`
+	SuspensionReason      string      `json:"suspension_reason,omitempty"`
+	PasswordResetRequired bool        `json:"password_reset_required"`
+	DeletionScheduledAt   *time.Time  `json:"deletion_scheduled_at,omitempty"`
+	DeletedAt             *time.Time  `json:"deleted_at,omitempty"`
+	UpdatedAt             time.Time   `json:"updated_at"`
+}
+
+// UserSearch décrit les critères de recherche des comptes
+type UserSearch struct {
+	Query  string // Sous-chaîne du nom d'utilisateur ou de l'email
+	Role   models.Role
+	Status string // active, suspended ou deleted
+	Limit  int
+	Offset int
+}
+
+// UserActivity résume l'activité récente d'un compte
+type UserActivity struct {
+	User               AdminUser         `json:"user"`
+	Sessions           []models.Session  `json:"sessions"`
+	APIKeys            []models.APIKey   `json:"api_keys"`
+	Events             []ActivityEvent   `json:"events"`
+	RSVPs              []models.RSVP     `json:"rsvps"`
+	MessagesSent       int64             `json:"messages_sent_30d"`
+	FriendRequestsSent int64             `json:"friend_requests_sent_30d"`
+	AuditLog           []models.AuditLog `json:"audit_log"`
+}
+
+// ActivityEvent est la forme abrégée d'un événement organisé par l'utilisateur
+type ActivityEvent struct {
+	ID               int64                   `json:"id"`
+	Title            string                  `json:"title"`
+	PublicationState models.PublicationState `json:"publication_state"`
+	EventDate        time.Time               `json:"event_date"`
+	CreatedAt        time.Time               `json:"created_at"`
+}
+
+// AdminUserService regroupe les actions de modération des comptes.
+// Chaque action est écrite dans le journal d'audit, dans la même transaction que la modification.
+type AdminUserService struct {
+	DB           *gorm.DB
+	AuthService  *AuthService
+	EmailService *EmailService
+}
+
+// NewAdminUserService crée une nouvelle instance de AdminUserService
+func NewAdminUserService(db *gorm.DB, authService *AuthService, emailService *EmailService) *AdminUserService {
+	return &AdminUserService{
+		DB:           db,
+		AuthService:  authService,
+		EmailService: emailService,
+	}
+}
+
+func adminView(user models.Users) AdminUser {
+	return AdminUser{
+		ID:                    user.ID,
+		Username:              user.Username,
+		Email:                 user.Email,
+		Role:                  user.Role,
+		IsConfirmed:           user.IsConfirmed,
+		TOTPEnabled:           user.TOTPEnabled,
+		Suspended:             user.IsSuspended(time.Now()),
+		SuspendedAt:           user.SuspendedAt,
+		SuspendedUntil:        user.SuspendedUntil,
+		SuspensionReason:      user.SuspensionReason,
+		PasswordResetRequired: user.PasswordResetRequired,
+		DeletionScheduledAt:   user.DeletionScheduledAt,
+		DeletedAt:             user.DeletedAt,
+		UpdatedAt:             user.UpdatedAt,
+	}
+}
+
+// SearchUsers recherche des comptes par nom d'utilisateur ou email, rôle et statut
+func (s *AdminUserService) SearchUsers(search UserSearch) ([]AdminUser, int64, error) {
+	query := s.DB.Model(&models.Users{})
+	if q := strings.TrimSpace(search.Query); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR id = ?", pattern, pattern, strings.ToUpper(q))
+	}
+	if search.Role != "" {
+		if !validRole(search.Role) {
+			return nil, 0, ErrInvalidRole
+		}
+		query = query.Where("role = ?", search.Role)
+	}
+	now := time.Now()
+	switch search.Status {
+	case "":
+	case "active":
+		query = query.Where("deleted_at IS NULL AND (suspended_at IS NULL OR suspended_until <= ?)", now)
+	case "suspended":
+		query = query.Where("deleted_at IS NULL AND suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > ?)", now)
+	case "deleted":
+		query = query.Where("deleted_at IS NOT NULL")
+	default:
+		return nil, 0, ErrInvalidUserStatus
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	limit := search.Limit
+	if limit <= 0 {
+		limit = adminSearchDefaultLimit
+	} else if limit > adminSearchMaxLimit {
+		limit = adminSearchMaxLimit
+	}
+	offset := search.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	var users []models.Users
+	if err := query.Order("username").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	views := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		views = append(views, adminView(user))
+	}
+	return views, total, nil
+}
+
+// ChangeRole modifie le rôle d'un utilisateur (par exemple user → organizer).
+// Le rôle est relu à chaque requête par RequireRole : le changement est immédiat.
+func (s *AdminUserService) ChangeRole(actor AuditActor, userID string, role models.Role, reason string) (AdminUser, error) {
+	if !validRole(role) {
+		return AdminUser{}, ErrInvalidRole
+	}
+	if userID == actor.ID {
+		return AdminUser{}, ErrCannotModerateSelf
+	}
+
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	if user.Role == role {
+		return adminView(user), nil
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Update("role", role).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditRoleChanged, user.ID, reason, map[string]interface{}{
+			"from": user.Role,
+			"to":   role,
+		})
+	})
+	if err != nil {
+		return AdminUser{}, err
+	}
+
+	user.Role = role
+	return adminView(user), nil
+}
+
+// SuspendUser suspend un compte jusqu'à until, ou le bannit si until est nul.
+// Toutes les sessions sont révoquées ; la connexion, les tokens d'accès et les clés d'API sont refusés
+// tant que la suspension court.
+func (s *AdminUserService) SuspendUser(actor AuditActor, userID, reason string, until *time.Time) (AdminUser, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return AdminUser{}, ErrReasonRequired
+	}
+	if until != nil && !until.After(time.Now()) {
+		return AdminUser{}, ErrInvalidSuspensionUntil
+	}
+	if userID == actor.ID {
+		return AdminUser{}, ErrCannotModerateSelf
+	}
+
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	if user.Role == models.RoleAdmin {
+		return AdminUser{}, ErrCannotModerateAdmin
+	}
+
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"suspended_at":      now,
+			"suspended_until":   until,
+			"suspension_reason": reason,
+			"tokens_revoked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID, now); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditUserSuspended, user.ID, reason, map[string]interface{}{
+			"until":    until,
+			"previous": previousSuspension(user),
+		})
+	})
+	if err != nil {
+		return AdminUser{}, err
+	}
+
+	user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason = &now, until, reason
+	s.notifySuspension(user)
+	return adminView(user), nil
+}
+
+// ReinstateUser lève la suspension ou le bannissement d'un compte
+func (s *AdminUserService) ReinstateUser(actor AuditActor, userID, reason string) (AdminUser, error) {
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	if !user.IsSuspended(time.Now()) {
+		return AdminUser{}, ErrNotSuspended
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"suspended_at":      nil,
+			"suspended_until":   nil,
+			"suspension_reason": "",
+		}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditUserReinstated, user.ID, reason, map[string]interface{}{
+			"previous": previousSuspension(user),
+		})
+	})
+	if err != nil {
+		return AdminUser{}, err
+	}
+
+	user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason = nil, nil, ""
+	return adminView(user), nil
+}
+
+// ForcePasswordReset révoque toutes les sessions et refuse le mot de passe actuel
+// jusqu'à ce que l'utilisateur en choisisse un nouveau via le lien envoyé par email.
+func (s *AdminUserService) ForcePasswordReset(actor AuditActor, userID, reason string) (AdminUser, error) {
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"password_reset_required": true,
+			"tokens_revoked_at":       now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID, now); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditPasswordResetForced, user.ID, reason, nil)
+	})
+	if err != nil {
+		return AdminUser{}, err
+	}
+
+	if err := s.AuthService.RequestPasswordReset(user.Email); err != nil {
+		return AdminUser{}, err
+	}
+
+	user.PasswordResetRequired = true
+	return adminView(user), nil
+}
+
+// UnlockUser lève le blocage de connexion consécutif à des échecs répétés
+func (s *AdminUserService) UnlockUser(actor AuditActor, userID, reason string) error {
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.AuthService.UnlockUser(user.ID); err != nil {
+		return err
+	}
+	return recordAudit(s.DB, actor, models.AuditUserUnlocked, user.ID, reason, nil)
+}
+
+// GetUserActivity retourne l'activité récente d'un compte ; la consultation est elle-même tracée
+func (s *AdminUserService) GetUserActivity(actor AuditActor, userID string) (UserActivity, error) {
+	user, err := s.loadTarget(userID)
+	if err != nil {
+		return UserActivity{}, err
+	}
+
+	activity := UserActivity{User: adminView(user)}
+	since := time.Now().Add(-activityWindow)
+
+	if err := s.DB.Where("user_id = ?", user.ID).Order("last_seen_at DESC").Limit(activityListLimit).Find(&activity.Sessions).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if err := s.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(activityListLimit).Find(&activity.APIKeys).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if err := s.DB.Model(&models.Event{}).Unscoped().
+		Select("id", "title", "publication_state", "event_date", "created_at").
+		Where("organizer_id = ?", user.ID).Order("created_at DESC").Limit(activityListLimit).
+		Scan(&activity.Events).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if err := s.DB.Where("user_id = ?", user.ID).Order("updated_at DESC").Limit(activityListLimit).Find(&activity.RSVPs).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if err := s.DB.Model(&models.Message{}).Where("sender_id = ? AND created_at > ?", user.ID, since).Count(&activity.MessagesSent).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if err := s.DB.Model(&models.FriendRequest{}).Where("sender_id = ? AND created_at > ?", user.ID, since).Count(&activity.FriendRequestsSent).Error; err != nil {
+		return UserActivity{}, err
+	}
+	if activity.AuditLog, err = s.GetAuditLog(user.ID, 20); err != nil {
+		return UserActivity{}, err
+	}
+
+	if err := recordAudit(s.DB, actor, models.AuditActivityViewed, user.ID, "", nil); err != nil {
+		return UserActivity{}, err
+	}
+	return activity, nil
+}
+
+// GetAuditLog retourne les dernières entrées du journal d'audit, éventuellement limitées à un compte
+func (s *AdminUserService) GetAuditLog(targetUserID string, limit int) ([]models.AuditLog, error) {
+	if limit <= 0 {
+		limit = adminSearchDefaultLimit
+	} else if limit > adminSearchMaxLimit {
+		limit = adminSearchMaxLimit
+	}
+
+	query := s.DB.Order("created_at DESC").Limit(limit)
+	if targetUserID != "" {
+		query = query.Where("target_user_id = ?", targetUserID)
+	}
+
+	logs := []models.AuditLog{}
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// loadTarget charge le compte visé par une action d'administration
+func (s *AdminUserService) loadTarget(userID string) (models.Users, error) {
+	var user models.Users
+	if err := s.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Users{}, ErrUserNotFound
+		}
+		return models.Users{}, err
+	}
+	return user, nil
+}
+
+// recordAudit écrit une entrée du journal d'audit
+func recordAudit(tx *gorm.DB, actor AuditActor, action models.AuditAction, targetUserID, reason string, details map[string]interface{}) error {
+	entry := models.AuditLog{
+		ID:           ulid.Make().String(),
+		ActorID:      actor.ID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Reason:       strings.TrimSpace(reason),
+		IP:           truncate(actor.IP, 45),
+	}
+	if len(details) > 0 {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(encoded)
+	}
+	return tx.Create(&entry).Error
+}
+
+// notifySuspension informe l'utilisateur de sa suspension et de son motif
+func (s *AdminUserService) notifySuspension(user models.Users) {
+	paragraphs := []string{"Motif : " + user.SuspensionReason}
+	heading := "Votre compte a été banni"
+	if user.SuspendedUntil != nil {
+		heading = "Votre compte a été suspendu"
+		paragraphs = append(paragraphs, "La suspension prendra fin le "+user.SuspendedUntil.Format("02/01/2006 à 15:04")+".")
+	}
+
+	go func() {
+		if err := s.EmailService.SendNotificationEmail(NotificationEmail{
+			ToEmail:    user.Email,
+			Subject:    heading,
+			Heading:    heading,
+			Paragraphs: paragraphs,
+		}); err != nil {
+			log.Printf("Error sending suspension email: %v", err)
+		}
+	}()
+}
+
+// previousSuspension décrit la suspension en cours avant modification, pour le journal d'audit
+func previousSuspension(user models.Users) map[string]interface{} {
+	if user.SuspendedAt == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"suspended_at": user.SuspendedAt,
+		"until":        user.SuspendedUntil,
+		"reason":       user.SuspensionReason,
+	}
+}
+
+func validRole(role models.Role) bool {
+	return role == models.RoleUser || role == models.RoleOrganizer || role == models.RoleAdmin
+}
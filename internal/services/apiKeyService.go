@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/helpers"
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKeyScope  = errors.New("invalid api key scope")
+	ErrAPIKeyNameRequired  = errors.New("api key name is required")
+	ErrTooManyAPIKeys      = errors.New("too many active api keys")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiration must be in the future")
+)
+
+const (
+	// maxActiveAPIKeys limite le nombre de clés actives par utilisateur
+	maxActiveAPIKeys = 20
+	// apiKeyTouchInterval limite les écritures de last_used_at pour les scripts très actifs
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService gère les clés d'API personnelles
+type APIKeyService struct {
+	DB *gorm.DB
+}
+
+// NewAPIKeyService crée une nouvelle instance de APIKeyService
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{DB: db}
+}
+
+// CreateKey crée une clé pour l'utilisateur et retourne sa valeur en clair, affichée une seule fois.
+// La clé a la forme fmk_<prefix>_<secret> ; seule l'empreinte de la clé complète est conservée.
+func (s *APIKeyService) CreateKey(userID, name string, scopes []models.APIKeyScope, expiresAt *time.Time) (string, models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.APIKey{}, ErrAPIKeyNameRequired
+	}
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", models.APIKey{}, ErrInvalidAPIKeyExpiry
+	}
+
+	var active int64
+	if err := s.DB.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&active).Error; err != nil {
+		return "", models.APIKey{}, err
+	}
+	if active >= maxActiveAPIKeys {
+		return "", models.APIKey{}, ErrTooManyAPIKeys
+	}
+
+	prefix, err := helpers.GenerateSecureToken(6)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	secret, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	prefix = middlewares.APIKeyPrefix + prefix
+	plain := prefix + "_" + secret
+
+	key := models.APIKey{
+		ID:        ulid.Make().String(),
+		UserID:    userID,
+		Name:      truncate(name, 100),
+		Prefix:    prefix,
+		KeyHash:   helpers.HashToken(plain),
+		Scopes:    joinScopes(normalized),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.DB.Create(&key).Error; err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	return plain, key, nil
+}
+
+// GetKeys retourne les clés de l'utilisateur, y compris révoquées ou expirées, les plus récentes en premier
+func (s *APIKeyService) GetKeys(userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey révoque une clé de l'utilisateur ; elle est refusée dès la requête suivante
+func (s *APIKeyService) RevokeKey(userID, keyID string) error {
+	res := s.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate vérifie une clé présentée et enregistre sa dernière utilisation.
+// Elle est enregistrée auprès de JWTMiddleware au démarrage du serveur.
+func (s *APIKeyService) Authenticate(plain, ip string) (*middlewares.APIKeyPrincipal, error) {
+	separator := strings.LastIndex(plain, "_")
+	if !strings.HasPrefix(plain, middlewares.APIKeyPrefix) || separator <= len(middlewares.APIKeyPrefix) {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := s.DB.Where("prefix = ?", plain[:separator]).First(&key).Error; err != nil {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(helpers.HashToken(plain))) != 1 {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+
+	var user models.Users
+	if err := s.DB.Select("id", "suspended_at", "suspended_until").Where("id = ?", key.UserID).First(&user).Error; err != nil {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+	// Les clés d'un compte suspendu sont refusées le temps de la suspension
+	if user.IsSuspended(now) {
+		return nil, middlewares.ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval || key.LastUsedIP != ip {
+		s.DB.Model(&models.APIKey{}).Where("id = ?", key.ID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	return &middlewares.APIKeyPrincipal{
+		KeyID:  key.ID,
+		UserID: key.UserID,
+		Scopes: splitScopes(key.Scopes),
+	}, nil
+}
+
+// normalizeScopes valide les scopes demandés et supprime les doublons
+func normalizeScopes(scopes []models.APIKeyScope) ([]models.APIKeyScope, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	seen := make(map[models.APIKeyScope]bool)
+	var result []models.APIKeyScope
+	for _, scope := range scopes {
+		valid := false
+		for _, known := range models.APIKeyScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+func joinScopes(scopes []models.APIKeyScope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitScopes(scopes string) []models.APIKeyScope {
+	var result []models.APIKeyScope
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			result = append(result, models.APIKeyScope(scope))
+		}
+	}
+	return result
+}
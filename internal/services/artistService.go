@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+
+	models "github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+// ArtistService provides services for managing artists and their followers
+type ArtistService struct {
+	DB *gorm.DB
+}
+
+// NewArtistService creates a new instance of ArtistService
+func NewArtistService(db *gorm.DB) *ArtistService {
+	return &ArtistService{DB: db}
+}
+
+// GetArtistByID retrieves an artist by its ID
+func (s *ArtistService) GetArtistByID(artistID int) (*models.Artist, error) {
+	var artist models.Artist
+	if err := s.DB.Where("artist_id = ?", artistID).First(&artist).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("artist not found")
+		}
+		return nil, err
+	}
+	return &artist, nil
+}
+
+// FollowArtist makes a user follow an artist
+func (s *ArtistService) FollowArtist(userID string, artistID int) error {
+	if _, err := s.GetArtistByID(artistID); err != nil {
+		return err
+	}
+
+	follow := models.ArtistFollow{UserID: userID, ArtistID: artistID}
+	return s.DB.Where("user_id = ? AND artist_id = ?", userID, artistID).FirstOrCreate(&follow).Error
+}
+
+// UnfollowArtist makes a user stop following an artist
+func (s *ArtistService) UnfollowArtist(userID string, artistID int) error {
+	return s.DB.Where("user_id = ? AND artist_id = ?", userID, artistID).Delete(&models.ArtistFollow{}).Error
+}
+
+// GetFollowedArtists retrieves the artists followed by a user
+func (s *ArtistService) GetFollowedArtists(userID string) ([]models.Artist, error) {
+	var artists []models.Artist
+	err := s.DB.Joins("JOIN artist_follows ON artist_follows.artist_id = artists.artist_id").
+		Where("artist_follows.user_id = ?", userID).
+		Find(&artists).Error
+	if err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"log"
 	"math/rand"
 	"os"
 	"time"
@@ -10,33 +11,72 @@ import (
 	middlewares "github.com/mackenzii/freemusic/internal/middleware"
 	"github.com/mackenzii/freemusic/internal/models"
 	"github.com/oklog/ulid/v2"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, session revoked")
+	ErrSessionRevoked      = errors.New("session revoked")
+	ErrInvalidResetToken   = errors.New("invalid or expired reset token")
+	ErrWeakPassword        = errors.New("password must be at least 8 characters long")
+	ErrEmailNotConfirmed   = errors.New("email not confirmed")
+	ErrInvalidConfirmation = errors.New("invalid or expired confirmation token")
+	ErrEmailTaken          = errors.New("email already in use")
+	ErrPasswordResetNeeded = errors.New("password reset required, check your email")
+)
+
+// SuspendedError est retournée à la connexion, et par JWTMiddleware, pour un compte suspendu ou banni
+type SuspendedError struct {
+	Reason string
+	Until  *time.Time // Nul pour un bannissement
+}
+
+func (e *SuspendedError) Error() string {
+	if e.Until == nil {
+		return "account banned: " + e.Reason
+	}
+	return "account suspended until " + e.Until.UTC().Format(time.RFC3339) + ": " + e.Reason
+}
+
+// suspensionError retourne une SuspendedError si le compte est suspendu ou banni
+func suspensionError(user models.Users) error {
+	if !user.IsSuspended(time.Now()) {
+		return nil
+	}
+	return &SuspendedError{Reason: user.SuspensionReason, Until: user.SuspendedUntil}
+}
+
 // AuthService fournit des services d'authentification
 type AuthService struct {
-	DB                *gorm.DB
-	GoogleOauthConfig *oauth2.Config
-	ImageService      *ImageService
-	EmailService      *EmailService
+	DB           *gorm.DB
+	ImageService *ImageService
+	EmailService *EmailService
+	LoginGuard   *LoginGuardService
+
+	// dummyPasswordHash est comparé quand l'email est inconnu, pour que la durée de réponse ne trahisse pas l'existence du compte
+	dummyPasswordHash string
+
+	// RequireEmailConfirmation bloque la connexion et les actions sensibles tant que l'email n'est pas confirmé
+	RequireEmailConfirmation bool
 }
 
 // NewAuthService crée une nouvelle instance de AuthService
-func NewAuthService(db *gorm.DB, imageService *ImageService, emailService *EmailService) *AuthService {
-	googleOauthConfig := &oauth2.Config{
-		ClientID:    os.Getenv("GOOGLE_CLIENT_ID"),
-		RedirectURL: os.Getenv("GOOGLE_REDIRECT_URI"),
-		Scopes:      []string{"https://www.googleapis.com/auth/userinfo.email"},
-		Endpoint:    google.Endpoint,
+func NewAuthService(db *gorm.DB, imageService *ImageService, emailService *EmailService, loginGuard *LoginGuardService) *AuthService {
+	dummyPasswordHash, err := helpers.HashPassword(ulid.Make().String())
+	if err != nil {
+		log.Printf("Error generating dummy password hash: %v", err)
 	}
 
 	return &AuthService{
 		DB:                db,
-		GoogleOauthConfig: googleOauthConfig,
 		ImageService:      imageService,
 		EmailService:      emailService,
+		LoginGuard:        loginGuard,
+		dummyPasswordHash: dummyPasswordHash,
+
+		RequireEmailConfirmation: os.Getenv("EMAIL_CONFIRMATION_REQUIRED") != "false",
 	}
 }
 
@@ -51,17 +91,25 @@ func (s *AuthService) RegisterUser(userInfo models.Users) (models.Users, error)
 		return models.Users{}, err
 	}
 
-	// Générer un jeton de confirmation unique
-	confirmationToken := ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+	// Générer un jeton de confirmation unique ; seule son empreinte est stockée
+	confirmationToken, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return models.Users{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(ConfirmationTokenTTL)
 
 	// Créer l'utilisateur
 	user := models.Users{
-		ID:                newID.String(),
-		Username:          userInfo.Username,
-		Email:             userInfo.Email,
-		PasswordHash:      hashedPassword,
-		IsConfirmed:       false,
-		ConfirmationToken: confirmationToken,
+		ID:                 newID.String(),
+		Username:           userInfo.Username,
+		Email:              userInfo.Email,
+		PasswordHash:       hashedPassword,
+		Location:           userInfo.Location,
+		IsConfirmed:        false,
+		ConfirmationToken:  helpers.HashToken(confirmationToken),
+		TokenExpiresAt:     &expiresAt,
+		ConfirmationSentAt: &now,
 	}
 
 	// Sauvegarder l'utilisateur dans la base de données
@@ -70,103 +118,331 @@ func (s *AuthService) RegisterUser(userInfo models.Users) (models.Users, error)
 	}
 
 	// Envoyer un email de confirmation avec le jeton
-	if err := s.EmailService.SendConfirmationEmail(user.Email, user.ConfirmationToken); err != nil {
+	if err := s.EmailService.SendConfirmationEmail(user.Email, confirmationToken); err != nil {
 		return models.Users{}, err
 	}
 
 	return user, nil
 }
 
-// Login authentifie un utilisateur et retourne un accessToken et un refreshToken
-func (s *AuthService) Login(email, password string) (string, string, error) {
-	var user models.Users
-	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return "", "", err
+// ConfirmationTokenTTL est la durée de validité d'un lien de confirmation d'adresse
+const ConfirmationTokenTTL = 48 * time.Hour
+
+// ConfirmationResendInterval est le délai minimal entre deux envois d'un email de confirmation
+const ConfirmationResendInterval = time.Minute
+
+// ConfirmEmail valide un jeton de confirmation : soit celui de l'inscription,
+// soit celui d'un changement d'adresse, auquel cas la nouvelle adresse remplace l'ancienne.
+func (s *AuthService) ConfirmEmail(token string) error {
+	if token == "" {
+		return ErrInvalidConfirmation
 	}
+	hash := helpers.HashToken(token)
+	now := time.Now()
 
-	if !helpers.CheckPasswordHash(password, user.PasswordHash) {
-		ErrInvalidCredentials := errors.New("invalid credentials")
-		return "", "", ErrInvalidCredentials
+	var user models.Users
+	err := s.DB.Where("confirmation_token = ?", hash).First(&user).Error
+	if err == nil {
+		if user.TokenExpiresAt != nil && now.After(*user.TokenExpiresAt) {
+			return ErrInvalidConfirmation
+		}
+		return s.DB.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"is_confirmed":       true,
+			"confirmation_token": "",
+			"token_expires_at":   nil,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
 
-	userID, err := ulid.Parse(user.ID)
-	if err != nil {
-		return "", "", err
+	if err := s.DB.Where("email_change_token_hash = ?", hash).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidConfirmation
+		}
+		return err
+	}
+	if user.PendingEmail == "" || user.EmailChangeExpiresAt == nil || now.After(*user.EmailChangeExpiresAt) {
+		return ErrInvalidConfirmation
+	}
+	if taken, err := s.emailTaken(user.PendingEmail, user.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrEmailTaken
 	}
 
-	// Générer le token d'accès et le refresh token
-	accessToken, err := middlewares.GenerateToken(userID)
-	if err != nil {
-		return "", "", err
+	return s.DB.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"email":                   user.PendingEmail,
+		"is_confirmed":            true,
+		"pending_email":           "",
+		"email_change_token_hash": "",
+		"email_change_expires_at": nil,
+	}).Error
+}
+
+// ResendConfirmation renvoie un lien de confirmation à un compte non confirmé.
+//
+// Comme pour la réinitialisation du mot de passe, rien n'indique à l'appelant si l'adresse
+// est inscrite : les comptes inconnus, déjà confirmés ou relancés trop récemment sont ignorés.
+func (s *AuthService) ResendConfirmation(email string) error {
+	var user models.Users
+	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsConfirmed {
+		return nil
 	}
 
-	refreshToken, err := middlewares.GenerateRefreshToken(userID)
+	now := time.Now()
+	token, err := helpers.GenerateSecureToken(32)
 	if err != nil {
-		return "", "", err
+		return err
+	}
+	expiresAt := now.Add(ConfirmationTokenTTL)
+
+	// Mise à jour conditionnelle : deux demandes simultanées ne déclenchent qu'un seul envoi
+	res := s.DB.Model(&models.Users{}).
+		Where("id = ? AND (confirmation_sent_at IS NULL OR confirmation_sent_at < ?)", user.ID, now.Add(-ConfirmationResendInterval)).
+		Updates(map[string]interface{}{
+			"confirmation_token":   helpers.HashToken(token),
+			"token_expires_at":     expiresAt,
+			"confirmation_sent_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
 	}
 
-	return accessToken, refreshToken, nil
-}
+	go func() {
+		if err := s.EmailService.SendConfirmationEmail(user.Email, token); err != nil {
+			log.Printf("Error sending confirmation email: %v", err)
+		}
+	}()
 
-func (s *AuthService) GetAllUsers() ([]models.Users, error) {
+	return nil
+}
 
-	var users []models.Users
+// HasConfirmedEmail indique si l'utilisateur peut effectuer des actions sensibles.
+// Retourne toujours vrai lorsque la confirmation n'est pas exigée (EMAIL_CONFIRMATION_REQUIRED=false).
+func (s *AuthService) HasConfirmedEmail(id string) (bool, error) {
+	if !s.RequireEmailConfirmation {
+		return true, nil
+	}
+	var user models.Users
+	if err := s.DB.Select("is_confirmed").Where("id = ?", id).First(&user).Error; err != nil {
+		return false, err
+	}
+	return user.IsConfirmed, nil
+}
 
-	if err := s.DB.Find(&users).Error; err != nil {
+// emailTaken indique si une adresse est déjà utilisée par un autre compte
+func (s *AuthService) emailTaken(email, exceptID string) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.Users{}).Where("email = ? AND id <> ?", email, exceptID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
 
-		return nil, err
+// requestEmailChange enregistre la nouvelle adresse en attente et envoie le lien de vérification
+func (s *AuthService) requestEmailChange(user *models.Users, newEmail string) error {
+	if taken, err := s.emailTaken(newEmail, user.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrEmailTaken
+	}
 
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return err
 	}
+	expiresAt := time.Now().Add(ConfirmationTokenTTL)
+	user.PendingEmail = newEmail
+	user.EmailChangeTokenHash = helpers.HashToken(token)
+	user.EmailChangeExpiresAt = &expiresAt
+
+	go func() {
+		if err := s.EmailService.SendEmailChangeConfirmation(newEmail, token, ConfirmationTokenTTL); err != nil {
+			log.Printf("Error sending email change confirmation: %v", err)
+		}
+	}()
 
-	return users, nil
+	return nil
+}
 
+// LoginResult contient soit le couple accessToken/refreshToken, soit un ChallengeToken
+// lorsque l'utilisateur doit encore fournir son second facteur.
+type LoginResult struct {
+	AccessToken    string
+	RefreshToken   string
+	ChallengeToken string
 }
 
-func (s *AuthService) GetPublicUserInfoByID(userID string) (*models.Users, error) {
+// Login authentifie un utilisateur et retourne un accessToken et un refreshToken.
+// Si la double authentification est activée, seul un ChallengeToken est retourné (voir VerifyTwoFactorLogin).
+//
+// Les échecs sont comptés par compte et par IP (voir LoginGuardService) ; un email inconnu
+// produit la même erreur et le même blocage qu'un mauvais mot de passe.
+func (s *AuthService) Login(email, password string, client ClientInfo) (LoginResult, error) {
+	ip := client.IP
+	if err := s.LoginGuard.Check(email, ip); err != nil {
+		return LoginResult{}, err
+	}
 
 	var user models.Users
+	if err := s.DB.Where("email = ? AND deleted_at IS NULL", email).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return LoginResult{}, err
+		}
+		helpers.CheckPasswordHash(password, s.dummyPasswordHash)
+		s.LoginGuard.RegisterFailure(email, ip)
+		return LoginResult{}, ErrInvalidCredentials
+	}
 
-	if err := s.DB.Select("username", "email", "location", "role").Where("id = ?", userID).First(&user).Error; err != nil {
+	if !helpers.CheckPasswordHash(password, user.PasswordHash) {
+		s.registerLoginFailure(user, ip)
+		return LoginResult{}, ErrInvalidCredentials
+	}
 
-		return nil, err
+	// Avec la double authentification, les échecs ne sont effacés qu'une fois le code vérifié
+	// (VerifyTwoFactorLogin) : un mot de passe connu ne doit pas permettre de tester des codes sans limite
+	if !user.TOTPEnabled {
+		if err := s.LoginGuard.Reset(email); err != nil {
+			log.Printf("Error resetting login failures: %v", err)
+		}
+	}
 
+	if err := suspensionError(user); err != nil {
+		return LoginResult{}, err
+	}
+	// Réinitialisation imposée par un administrateur : le mot de passe actuel n'est plus accepté
+	if user.PasswordResetRequired {
+		return LoginResult{}, ErrPasswordResetNeeded
 	}
 
-	return &user, nil
+	if s.RequireEmailConfirmation && !user.IsConfirmed {
+		return LoginResult{}, ErrEmailNotConfirmed
+	}
 
+	return s.completeLogin(user, client)
 }
 
-func (s *AuthService) DeleteUserAndRelatedData(userID string) error {
+// completeLogin émet les tokens d'un utilisateur authentifié, ou un ChallengeToken si la double authentification est activée
+func (s *AuthService) completeLogin(user models.Users, client ClientInfo) (LoginResult, error) {
+	if err := suspensionError(user); err != nil {
+		return LoginResult{}, err
+	}
+
+	if user.TOTPEnabled {
+		userID, err := ulid.Parse(user.ID)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		challengeToken, err := middlewares.GenerateMFAChallengeToken(userID)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{ChallengeToken: challengeToken}, nil
+	}
 
-	// Implement the logic to delete user and related data from the database
+	accessToken, refreshToken, err := s.IssueTokens(user.ID, client)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// registerLoginFailure compte un échec sur un compte existant et prévient son propriétaire au moment du blocage
+func (s *AuthService) registerLoginFailure(user models.Users, ip string) {
+	if !s.LoginGuard.RegisterFailure(user.Email, ip) {
+		return
+	}
 
-	if err := s.DB.Where("id = ?", userID).Delete(&models.Users{}).Error; err != nil {
+	go func() {
+		if err := s.EmailService.SendNotificationEmail(NotificationEmail{
+			ToEmail: user.Email,
+			Subject: "Tentatives de connexion suspectes",
+			Heading: "Votre compte a été temporairement bloqué",
+			Paragraphs: []string{
+				"Plusieurs tentatives de connexion échouées ont été détectées sur votre compte.",
+				"Par sécurité, la connexion est temporairement bloquée.",
+				"Si vous n'êtes pas à l'origine de ces tentatives, nous vous conseillons de réinitialiser votre mot de passe.",
+			},
+		}); err != nil {
+			log.Printf("Error sending lockout alert: %v", err)
+		}
+	}()
+}
 
+// UnlockUser lève le blocage de connexion d'un utilisateur (action d'administration)
+func (s *AuthService) UnlockUser(id string) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
 		return err
+	}
+	return s.LoginGuard.Reset(user.Email)
+}
 
+// IssueTokens ouvre une nouvelle session (famille de refresh tokens) pour l'appareil client
+// et génère le token d'accès associé. L'utilisateur est prévenu par email si l'appareil est nouveau.
+func (s *AuthService) IssueTokens(userID string, client ClientInfo) (string, string, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Add logic to delete related data if necessary
+	sessionID := ulid.Make().String()
+	var refreshToken string
+	var session models.Session
+	var newDevice bool
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, newDevice, err = s.createSession(tx, userID, sessionID, client); err != nil {
+			return err
+		}
+		refreshToken, _, err = s.issueRefreshToken(tx, id, sessionID)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if newDevice {
+		s.notifyNewDevice(userID, session)
+	}
 
-	return nil
+	accessToken, err := middlewares.GenerateToken(id, sessionID)
+	if err != nil {
+		return "", "", err
+	}
 
+	return accessToken, refreshToken, nil
 }
 
-// UpdateRefreshToken met à jour le refresh token d'un utilisateur
-func (s *AuthService) UpdateRefreshToken(email, refreshToken string) error {
-	var user models.Users
-	// Rechercher l'utilisateur par email
-	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return err
+// issueRefreshToken signe un refresh token et enregistre son empreinte dans la famille donnée
+func (s *AuthService) issueRefreshToken(tx *gorm.DB, userID ulid.ULID, familyID string) (string, string, error) {
+	tokenID := ulid.Make().String()
+	signed, err := middlewares.GenerateRefreshToken(userID, tokenID)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Mettre à jour le refresh token
-	user.RefreshToken = refreshToken
-	if err := s.DB.Save(&user).Error; err != nil {
-		return err
+	record := models.RefreshToken{
+		ID:        tokenID,
+		UserID:    userID.String(),
+		FamilyID:  familyID,
+		TokenHash: helpers.HashToken(signed),
+		ExpiresAt: time.Now().Add(middlewares.RefreshTokenTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", "", err
 	}
 
-	return nil
+	return signed, tokenID, nil
 }
 
 // GetUserByEmail récupère un utilisateur par son adresse email
@@ -179,23 +455,71 @@ func (s *AuthService) GetUserByEmail(email string) (models.Users, error) {
 	return user, nil
 }
 
-// Refresh génère un nouveau accessToken à partir d'un refreshToken valide
-func (s *AuthService) Refresh(refreshToken string) (string, string, error) {
+// Refresh échange un refresh token valide contre un nouveau couple de tokens (rotation).
+//
+// Le token présenté est consommé : s'il est présenté à nouveau, toute sa famille est révoquée,
+// ce qui déconnecte à la fois le voleur et l'utilisateur légitime.
+// L'activité de la session (dernière utilisation, IP) est mise à jour.
+func (s *AuthService) Refresh(refreshToken, ip string) (string, string, error) {
 	claims, err := middlewares.ParseToken(refreshToken)
 	if err != nil {
-		return "", "", err
+		return "", "", ErrInvalidRefreshToken
+	}
+	if claims.TokenType != middlewares.TokenTypeRefresh || claims.Id == "" {
+		return "", "", ErrInvalidRefreshToken
 	}
 
-	if claims.ExpiresAt < time.Now().Unix() {
-		return "", "", errors.New("refresh token expired")
+	var record models.RefreshToken
+	if err := s.DB.Where("id = ?", claims.Id).First(&record).Error; err != nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+	if record.TokenHash != helpers.HashToken(refreshToken) || record.UserID != claims.UserID.String() {
+		return "", "", ErrInvalidRefreshToken
+	}
+	if record.RevokedAt != nil {
+		if err := s.revokeFamily(record.FamilyID); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
 	}
+	if time.Now().After(record.ExpiresAt) {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	var newRefreshToken string
+	reused := false
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var newID string
+		var err error
+		newRefreshToken, newID, err = s.issueRefreshToken(tx, claims.UserID, record.FamilyID)
+		if err != nil {
+			return err
+		}
 
-	accessToken, err := middlewares.GenerateToken(claims.UserID)
+		// Révocation conditionnelle : deux rafraîchissements concurrents ne peuvent pas consommer le même token
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", record.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": newID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			reused = true
+			return ErrRefreshTokenReused
+		}
+		return s.touchSession(tx, record.FamilyID, ip)
+	})
+	if reused {
+		if err := s.revokeFamily(record.FamilyID); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
 	if err != nil {
 		return "", "", err
 	}
 
-	newRefreshToken, err := middlewares.GenerateRefreshToken(claims.UserID)
+	accessToken, err := middlewares.GenerateToken(claims.UserID, record.FamilyID)
 	if err != nil {
 		return "", "", err
 	}
@@ -203,6 +527,166 @@ func (s *AuthService) Refresh(refreshToken string) (string, string, error) {
 	return accessToken, newRefreshToken, nil
 }
 
+// Logout révoque la famille du refresh token présenté (déconnexion de cet appareil)
+func (s *AuthService) Logout(refreshToken string) error {
+	claims, err := middlewares.ParseToken(refreshToken)
+	if err != nil || claims.TokenType != middlewares.TokenTypeRefresh || claims.Id == "" {
+		return ErrInvalidRefreshToken
+	}
+
+	var record models.RefreshToken
+	if err := s.DB.Where("id = ?", claims.Id).First(&record).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+	if record.TokenHash != helpers.HashToken(refreshToken) {
+		return ErrInvalidRefreshToken
+	}
+
+	return s.revokeFamily(record.FamilyID)
+}
+
+// LogoutAll révoque tous les refresh tokens de l'utilisateur et invalide ses tokens d'accès déjà émis
+func (s *AuthService) LogoutAll(userID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := revokeUserSessions(tx, userID, now); err != nil {
+			return err
+		}
+		return tx.Model(&models.Users{}).Where("id = ?", userID).Update("tokens_revoked_at", now).Error
+	})
+}
+
+// ValidateSession vérifie qu'un token d'accès n'a pas été révoqué par une déconnexion globale,
+// par la révocation de sa session ou par la suspension du compte, et enregistre l'activité de la session.
+// Elle est enregistrée auprès de JWTMiddleware au démarrage du serveur.
+func (s *AuthService) ValidateSession(claims *middlewares.Claims) error {
+	var user models.Users
+	if err := s.DB.Select("id", "tokens_revoked_at", "suspended_at", "suspended_until", "suspension_reason").
+		Where("id = ? AND deleted_at IS NULL", claims.UserID.String()).First(&user).Error; err != nil {
+		return ErrSessionRevoked
+	}
+	if err := suspensionError(user); err != nil {
+		return err
+	}
+	if user.TokensRevokedAt != nil && claims.IssuedBefore(*user.TokensRevokedAt) {
+		return ErrSessionRevoked
+	}
+
+	// Les tokens émis avant l'introduction des sessions n'ont pas de sid
+	if claims.SessionID == "" {
+		return nil
+	}
+	var session models.Session
+	if err := s.DB.Select("id", "revoked_at", "last_seen_at").
+		Where("id = ? AND user_id = ?", claims.SessionID, user.ID).
+		First(&session).Error; err != nil || session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.DB.Model(&models.Session{}).Where("id = ?", session.ID).Update("last_seen_at", time.Now()).Error; err != nil {
+			log.Printf("Error updating session activity: %v", err)
+		}
+	}
+	return nil
+}
+
+// revokeFamily révoque tous les refresh tokens encore actifs d'une famille, ainsi que la session correspondante
+func (s *AuthService) revokeFamily(familyID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// PasswordResetTTL est la durée de validité d'un lien de réinitialisation
+const PasswordResetTTL = time.Hour
+
+// RequestPasswordReset génère un jeton de réinitialisation et l'envoie par email.
+//
+// Pour ne pas révéler si une adresse est inscrite, aucune erreur n'est retournée
+// lorsque l'utilisateur n'existe pas, et l'email est envoyé en arrière-plan.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	var user models.Users
+	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(PasswordResetTTL)
+	if err := s.DB.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password_reset_token_hash": helpers.HashToken(token),
+		"password_reset_expires_at": expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.EmailService.SendPasswordResetEmail(user.Email, token, PasswordResetTTL); err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword remplace le mot de passe à partir d'un jeton de réinitialisation valide.
+// Le jeton est consommé et toutes les sessions existantes sont révoquées.
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+	if len(newPassword) < 8 {
+		return ErrWeakPassword
+	}
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := helpers.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	var user models.Users
+	if err := s.DB.Select("id").
+		Where("password_reset_token_hash = ? AND password_reset_expires_at > ?", helpers.HashToken(token), time.Now()).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// Mise à jour conditionnelle : le jeton ne peut être consommé qu'une seule fois
+	res := s.DB.Model(&models.Users{}).
+		Where("id = ? AND password_reset_token_hash = ?", user.ID, helpers.HashToken(token)).
+		Updates(map[string]interface{}{
+			"password_hash":             hashedPassword,
+			"passwordless":              false,
+			"password_reset_token_hash": "",
+			"password_reset_expires_at": nil,
+			"password_reset_required":   false,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrInvalidResetToken
+	}
+
+	return s.LogoutAll(user.ID)
+}
+
 // GetUserByID récupère un utilisateur par son ID
 func (s *AuthService) GetUserByID(id string) (models.Users, error) {
 	var user models.Users
@@ -213,18 +697,43 @@ func (s *AuthService) GetUserByID(id string) (models.Users, error) {
 	return user, nil
 }
 
-// UpdateUser met à jour les informations de l'utilisateur
+// UpdateUser met à jour les informations de l'utilisateur.
+// Un changement d'adresse d'un compte confirmé n'est appliqué qu'après vérification de la nouvelle adresse.
 func (s *AuthService) UpdateUser(id string, userInfo models.Users) (models.Users, error) {
 	var user models.Users
 	if err := s.DB.Where("id = ?", id).First(&user).Error; err != nil {
 		return models.Users{}, err
 	}
 
+	confirmationToken := ""
+
 	if userInfo.Username != "" {
 		user.Username = userInfo.Username
 	}
-	if userInfo.Email != "" {
-		user.Email = userInfo.Email
+	if userInfo.Email != "" && userInfo.Email != user.Email {
+		if user.IsConfirmed {
+			// L'adresse actuelle reste active jusqu'à la vérification de la nouvelle
+			if err := s.requestEmailChange(&user, userInfo.Email); err != nil {
+				return models.Users{}, err
+			}
+		} else {
+			if taken, err := s.emailTaken(userInfo.Email, user.ID); err != nil {
+				return models.Users{}, err
+			} else if taken {
+				return models.Users{}, ErrEmailTaken
+			}
+			user.Email = userInfo.Email
+			token, err := helpers.GenerateSecureToken(32)
+			if err != nil {
+				return models.Users{}, err
+			}
+			now := time.Now()
+			expiresAt := now.Add(ConfirmationTokenTTL)
+			user.ConfirmationToken = helpers.HashToken(token)
+			user.TokenExpiresAt = &expiresAt
+			user.ConfirmationSentAt = &now
+			confirmationToken = token
+		}
 	}
 	if userInfo.PasswordHash != "" {
 		hashedPassword, err := helpers.HashPassword(userInfo.PasswordHash)
@@ -241,6 +750,13 @@ func (s *AuthService) UpdateUser(id string, userInfo models.Users) (models.Users
 		return models.Users{}, err
 	}
 
+	// Compte encore non confirmé : un nouveau lien est envoyé à la nouvelle adresse
+	if confirmationToken != "" {
+		if err := s.EmailService.SendConfirmationEmail(user.Email, confirmationToken); err != nil {
+			return models.Users{}, err
+		}
+	}
+
 	return user, nil
 }
 
@@ -257,3 +773,12 @@ func (s *AuthService) DeleteUser(id string) error {
 
 	return nil
 }
+
+// GetUserRole retourne le rôle courant d'un utilisateur
+func (s *AuthService) GetUserRole(id string) (models.Role, error) {
+	var user models.Users
+	if err := s.DB.Select("role").Where("id = ?", id).First(&user).Error; err != nil {
+		return "", err
+	}
+	return user.Role, nil
+}
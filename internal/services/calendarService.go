@@ -0,0 +1,365 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/helpers"
+	models "github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	icsProdID      = "-//FreeMusic//Events//FR"
+	icsUIDDomain   = "freemusic"
+	icsLineLimit   = 75
+	icsUTCLayout   = "20060102T150405Z"
+	icsLocalLayout = "20060102T150405"
+)
+
+// CalendarService builds iCalendar (RFC 5545) documents for events and user feeds
+type CalendarService struct {
+	DB           *gorm.DB
+	EventService *EventService
+}
+
+// NewCalendarService creates a new instance of CalendarService
+func NewCalendarService(db *gorm.DB, eventService *EventService) *CalendarService {
+	return &CalendarService{
+		DB:           db,
+		EventService: eventService,
+	}
+}
+
+// EventICS returns the .ics document of a single event visible to viewerID
+func (s *CalendarService) EventICS(eventID int, viewerID string) (string, error) {
+	event, err := s.EventService.GetVisibleEventByID(eventID, viewerID)
+	if err != nil {
+		return "", err
+	}
+	return s.buildCalendar(event.Title, []models.Event{*event})
+}
+
+// RotateFeedToken generates a new calendar feed token for a user, revoking the previous one.
+// The token is returned in clear once; only its hash is stored.
+func (s *CalendarService) RotateFeedToken(userID string) (string, error) {
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.DB.Model(&models.Users{}).Where("id = ?", userID).
+		Update("calendar_token_hash", helpers.HashToken(token)).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeFeedToken disables the calendar feed of a user
+func (s *CalendarService) RevokeFeedToken(userID string) error {
+	return s.DB.Model(&models.Users{}).Where("id = ?", userID).Update("calendar_token_hash", "").Error
+}
+
+// UserFeedICS returns the calendar feed of the user owning the given feed token: events they
+// RSVP'd to or hold tickets for, plus events of the artists they follow
+func (s *CalendarService) UserFeedICS(token string) (string, error) {
+	if token == "" {
+		return "", errors.New("invalid feed token")
+	}
+
+	var user models.Users
+	if err := s.DB.Where("calendar_token_hash = ?", helpers.HashToken(token)).First(&user).Error; err != nil {
+		return "", errors.New("invalid feed token")
+	}
+
+	var events []models.Event
+	err := s.DB.Scopes(s.EventService.visibleTo(user.ID, true)).Where("id IN (?) OR id IN (?) OR artist_id IN (?)",
+		s.DB.Model(&models.RSVP{}).Select("event_id").Where("user_id = ?", user.ID),
+		s.DB.Model(&models.Ticket{}).Select("event_id").Where("user_id = ?", user.ID),
+		s.DB.Model(&models.ArtistFollow{}).Select("artist_id").Where("user_id = ?", user.ID),
+	).Find(&events).Error
+	if err != nil {
+		return "", err
+	}
+
+	return s.buildCalendar("FreeMusic - "+user.Username, events)
+}
+
+// buildCalendar encodes events, their recurrence and exceptions as a VCALENDAR
+func (s *CalendarService) buildCalendar(name string, events []models.Event) (string, error) {
+	w := &icsWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icsProdID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + icsEscape(name))
+
+	// Time zones referenced by the events, with the transitions they span
+	zones := map[string][2]time.Time{}
+	for i := range events {
+		if events[i].TimeZone == "" {
+			continue
+		}
+		start, _, err := eventSeriesStart(&events[i])
+		if err != nil {
+			return "", err
+		}
+		span, ok := zones[events[i].TimeZone]
+		if !ok || start.Before(span[0]) {
+			span[0] = start
+		}
+		if end := seriesEnd(&events[i], start); !ok || end.After(span[1]) {
+			span[1] = end
+		}
+		zones[events[i].TimeZone] = span
+	}
+	names := make([]string, 0, len(zones))
+	for zone := range zones {
+		names = append(names, zone)
+	}
+	sort.Strings(names)
+	for _, zone := range names {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return "", err
+		}
+		writeVTimezone(w, loc, zones[zone][0], zones[zone][1])
+	}
+
+	for i := range events {
+		if err := s.writeEvent(w, &events[i]); err != nil {
+			return "", err
+		}
+	}
+
+	w.line("END:VCALENDAR")
+	return w.String(), nil
+}
+
+// writeEvent writes the master VEVENT of an event, followed by one overriding VEVENT
+// (same UID, RECURRENCE-ID) per cancelled or modified occurrence
+func (s *CalendarService) writeEvent(w *icsWriter, event *models.Event) error {
+	start, duration, err := eventSeriesStart(event)
+	if err != nil {
+		return err
+	}
+	uid := fmt.Sprintf("event-%d@%s", event.ID, icsUIDDomain)
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + uid)
+	w.line("DTSTAMP:" + event.UpdatedAt.UTC().Format(icsUTCLayout))
+	w.line("LAST-MODIFIED:" + event.UpdatedAt.UTC().Format(icsUTCLayout))
+	w.line(icsDateTime("DTSTART", start, event.TimeZone))
+	if duration > 0 {
+		w.line(icsDateTime("DTEND", start.Add(duration), event.TimeZone))
+	}
+	w.line("SUMMARY:" + icsEscape(event.Title))
+	if event.Description != "" {
+		w.line("DESCRIPTION:" + icsEscape(event.Description))
+	}
+	if event.Address != "" {
+		w.line("LOCATION:" + icsEscape(event.Address))
+	}
+	if event.Latitude != 0 || event.Longitude != 0 {
+		w.line(fmt.Sprintf("GEO:%f;%f", event.Latitude, event.Longitude))
+	}
+	if event.RRule != "" {
+		w.line("RRULE:" + event.RRule)
+	}
+	if event.Status == models.Cancelled {
+		w.line("STATUS:CANCELLED")
+	} else {
+		w.line("STATUS:CONFIRMED")
+	}
+	w.line("END:VEVENT")
+
+	if event.RRule == "" {
+		return nil
+	}
+
+	var exceptions []models.EventException
+	if err := s.DB.Where("event_id = ?", event.ID).Order("occurrence_date").Find(&exceptions).Error; err != nil {
+		return err
+	}
+	for _, ex := range exceptions {
+		occ := EventOccurrence{
+			OccurrenceDate: ex.OccurrenceDate.In(start.Location()),
+			StartTime:      ex.OccurrenceDate.In(start.Location()),
+			EndTime:        ex.OccurrenceDate.In(start.Location()).Add(duration),
+			Title:          event.Title,
+			Description:    event.Description,
+			Address:        event.Address,
+		}
+		applyException(&occ, ex)
+
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + uid)
+		w.line("DTSTAMP:" + ex.UpdatedAt.UTC().Format(icsUTCLayout))
+		w.line(icsDateTime("RECURRENCE-ID", occ.OccurrenceDate, event.TimeZone))
+		w.line(icsDateTime("DTSTART", occ.StartTime.In(start.Location()), event.TimeZone))
+		if occ.EndTime.After(occ.StartTime) {
+			w.line(icsDateTime("DTEND", occ.EndTime.In(start.Location()), event.TimeZone))
+		}
+		w.line("SUMMARY:" + icsEscape(occ.Title))
+		if occ.Description != "" {
+			w.line("DESCRIPTION:" + icsEscape(occ.Description))
+		}
+		if occ.Address != "" {
+			w.line("LOCATION:" + icsEscape(occ.Address))
+		}
+		if occ.Cancelled || event.Status == models.Cancelled {
+			w.line("STATUS:CANCELLED")
+		} else {
+			w.line("STATUS:CONFIRMED")
+		}
+		w.line("END:VEVENT")
+	}
+	return nil
+}
+
+// seriesEnd returns the last start of a series, or a few years ahead for open-ended rules.
+// Bounded rules (COUNT or UNTIL) are expanded over ten years at most, so that a distant UNTIL
+// does not stretch the VTIMEZONE over centuries.
+func seriesEnd(event *models.Event, start time.Time) time.Time {
+	if event.RRule == "" {
+		return start
+	}
+	rule, err := helpers.ParseRRule(event.RRule)
+	if err != nil || (rule.Count == 0 && rule.Until == nil) {
+		horizon := start
+		if now := time.Now(); now.After(horizon) {
+			horizon = now
+		}
+		return horizon.AddDate(5, 0, 0)
+	}
+	occurrences := rule.Between(start, start, start.AddDate(10, 0, 0))
+	if len(occurrences) == 0 {
+		return start
+	}
+	return occurrences[len(occurrences)-1]
+}
+
+// writeVTimezone writes a VTIMEZONE listing every UTC offset transition of loc between from and to
+func writeVTimezone(w *icsWriter, loc *time.Location, from, to time.Time) {
+	from = from.AddDate(0, 0, -1)
+	to = to.AddDate(0, 0, 1)
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+
+	name, offset := from.In(loc).Zone()
+	writeTZComponent(w, from.In(loc), name, offset, offset, isDST(loc, from))
+
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		_, nextOffset := next.In(loc).Zone()
+		if nextOffset == offset {
+			continue
+		}
+		// Narrow the transition down to the second
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(loc).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		newName, newOffset := hi.In(loc).Zone()
+		// DTSTART of a transition is expressed in the local time in effect before it
+		writeTZComponent(w, hi.In(time.FixedZone("", offset)), newName, offset, newOffset, isDST(loc, hi))
+		offset = newOffset
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+func writeTZComponent(w *icsWriter, start time.Time, name string, offsetFrom, offsetTo int, dst bool) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + start.Format(icsLocalLayout))
+	w.line("TZOFFSETFROM:" + icsOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + icsOffset(offsetTo))
+	if name != "" {
+		w.line("TZNAME:" + name)
+	}
+	w.line("END:" + kind)
+}
+
+// isDST reports whether t falls in the larger of the two offsets observed by loc during t's year
+func isDST(loc *time.Location, t time.Time) bool {
+	year := t.In(loc).Year()
+	_, jan := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+	_, jul := time.Date(year, time.July, 1, 0, 0, 0, 0, loc).Zone()
+	_, cur := t.In(loc).Zone()
+	if jan == jul {
+		return false
+	}
+	max := jan
+	if jul > jan {
+		max = jul
+	}
+	return cur == max
+}
+
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+// icsDateTime formats a date-time property, in local time with TZID when the event has a time zone
+func icsDateTime(property string, t time.Time, zone string) string {
+	if zone == "" {
+		return property + ":" + t.UTC().Format(icsUTCLayout)
+	}
+	return property + ";TZID=" + zone + ":" + t.Format(icsLocalLayout)
+}
+
+// icsEscape escapes a TEXT value (RFC 5545, section 3.3.11)
+func icsEscape(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, ";", "\\;")
+	value = strings.ReplaceAll(value, ",", "\\,")
+	value = strings.ReplaceAll(value, "\r\n", "\\n")
+	value = strings.ReplaceAll(value, "\n", "\\n")
+	return value
+}
+
+// icsWriter accumulates content lines, folding them at 75 octets with CRLF line endings
+type icsWriter struct {
+	b strings.Builder
+}
+
+func (w *icsWriter) line(content string) {
+	limit := icsLineLimit
+	for len(content) > limit {
+		cut := limit
+		// Never split a multi-byte UTF-8 sequence
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(content[:cut])
+		w.b.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = icsLineLimit - 1
+	}
+	w.b.WriteString(content)
+	w.b.WriteString("\r\n")
+}
+
+func (w *icsWriter) String() string {
+	return w.b.String()
+}
@@ -0,0 +1,584 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportNotFound      = errors.New("data export not found")
+	ErrExportInProgress    = errors.New("a data export is already in progress")
+	ErrExportTooSoon       = errors.New("a data export was already generated recently")
+	ErrExportNotReady      = errors.New("data export is not ready")
+	ErrInvalidDownloadLink = errors.New("invalid or expired download link")
+)
+
+const (
+	// DataExportLinkTTL est la durée de validité d'un lien de téléchargement signé
+	DataExportLinkTTL = time.Hour
+	// DataExportRetention est la durée de conservation d'une archive générée
+	DataExportRetention = 7 * 24 * time.Hour
+	// dataExportCooldown limite la fréquence des nouvelles demandes d'export
+	dataExportCooldown = 24 * time.Hour
+	// dataExportTimeout est la durée au-delà de laquelle un export en attente ou en cours est considéré
+	// comme interrompu (redémarrage du serveur pendant la génération...)
+	dataExportTimeout = 30 * time.Minute
+	// dataExportInterrupted est le message des exports interrompus
+	dataExportInterrupted = "the export was interrupted, please request a new one"
+)
+
+// DataExportService génère l'archive des données personnelles d'un utilisateur (droit d'accès RGPD)
+type DataExportService struct {
+	DB           *gorm.DB
+	EmailService *EmailService
+	ImageService *ImageService
+	// ExportDir contient les archives générées, supprimées après DataExportRetention
+	ExportDir string
+
+	signingKey []byte
+}
+
+// NewDataExportService crée une nouvelle instance de DataExportService.
+//
+// Les liens de téléchargement sont signés avec DATA_EXPORT_SIGNING_KEY ; sans cette variable une erreur
+// est retournée, sauf si ALLOW_EPHEMERAL_KEYS=true : une clé éphémère est alors générée (développement
+// uniquement : les liens déjà envoyés deviennent invalides au redémarrage).
+func NewDataExportService(db *gorm.DB, emailService *EmailService, imageService *ImageService) (*DataExportService, error) {
+	signingKey := []byte(os.Getenv("DATA_EXPORT_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		if !middlewares.EphemeralKeysAllowed() {
+			return nil, errors.New("DATA_EXPORT_SIGNING_KEY not set (set ALLOW_EPHEMERAL_KEYS=true to use an ephemeral key in development)")
+		}
+		log.Println("DATA_EXPORT_SIGNING_KEY not set, using an ephemeral key for export download links")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate export signing key: %w", err)
+		}
+	}
+
+	exportDir := os.Getenv("DATA_EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = "./exports"
+	}
+
+	return &DataExportService{
+		DB:           db,
+		EmailService: emailService,
+		ImageService: imageService,
+		ExportDir:    exportDir,
+		signingKey:   signingKey,
+	}, nil
+}
+
+// RequestExport enregistre une demande d'export et génère l'archive en tâche de fond.
+// L'utilisateur reçoit un email avec un lien de téléchargement lorsque l'archive est prête.
+func (s *DataExportService) RequestExport(userID string) (*models.DataExport, error) {
+	var last models.DataExport
+	err := s.DB.Where("user_id = ? AND status <> ?", userID, models.ExportFailed).
+		Order("created_at DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && isStale(last) {
+		// Un export interrompu ne doit pas bloquer l'utilisateur : il est marqué en échec
+		if _, err := s.markInterrupted(s.DB.Where("id = ?", last.ID)); err != nil {
+			return nil, err
+		}
+	} else if err == nil {
+		if last.Status == models.ExportPending || last.Status == models.ExportRunning {
+			return nil, ErrExportInProgress
+		}
+		if time.Since(last.CreatedAt) < dataExportCooldown {
+			return nil, ErrExportTooSoon
+		}
+	}
+
+	export := models.DataExport{
+		ID:     ulid.Make().String(),
+		UserID: userID,
+		Status: models.ExportPending,
+	}
+	if err := s.DB.Create(&export).Error; err != nil {
+		return nil, err
+	}
+
+	go s.runExport(export)
+
+	return &export, nil
+}
+
+// GetExports retourne les exports de l'utilisateur, les plus récents en premier
+func (s *DataExportService) GetExports(userID string) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// GetExport retourne un export appartenant à l'utilisateur
+func (s *DataExportService) GetExport(userID, exportID string) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := s.DB.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// DownloadURL génère un lien de téléchargement signé, valable DataExportLinkTTL, pour une archive prête
+func (s *DataExportService) DownloadURL(export *models.DataExport) (string, time.Time, error) {
+	if export.Status != models.ExportCompleted || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return "", time.Time{}, ErrExportNotReady
+	}
+
+	expires := time.Now().Add(DataExportLinkTTL)
+	if expires.After(*export.ExpiresAt) {
+		expires = *export.ExpiresAt
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{"expires": {exp}, "signature": {s.sign(export.ID, exp)}}
+
+	return apiBaseURL() + "/exports/" + export.ID + "/download?" + query.Encode(), expires, nil
+}
+
+// OpenDownload vérifie un lien signé et retourne le chemin de l'archive à servir
+func (s *DataExportService) OpenDownload(exportID, expires, signature string) (string, error) {
+	if !hmac.Equal([]byte(signature), []byte(s.sign(exportID, expires))) {
+		return "", ErrInvalidDownloadLink
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return "", ErrInvalidDownloadLink
+	}
+
+	var export models.DataExport
+	if err := s.DB.Where("id = ? AND status = ?", exportID, models.ExportCompleted).First(&export).Error; err != nil {
+		return "", ErrInvalidDownloadLink
+	}
+	if export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return "", ErrInvalidDownloadLink
+	}
+	if _, err := os.Stat(export.FilePath); err != nil {
+		return "", ErrInvalidDownloadLink
+	}
+	return export.FilePath, nil
+}
+
+func (s *DataExportService) sign(exportID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(exportID + "." + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PurgeExpiredExports supprime les archives dont la durée de conservation est dépassée
+func (s *DataExportService) PurgeExpiredExports() (int, error) {
+	var exports []models.DataExport
+	if err := s.DB.Where("status = ? AND expires_at <= ?", models.ExportCompleted, time.Now()).Find(&exports).Error; err != nil {
+		return 0, err
+	}
+
+	for _, export := range exports {
+		if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove data export %s: %v", export.ID, err)
+			continue
+		}
+		s.DB.Model(&export).Updates(map[string]interface{}{"status": models.ExportExpired, "file_path": ""})
+	}
+	return len(exports), nil
+}
+
+// FailStaleExports marque en échec les exports en attente ou en cours depuis plus de dataExportTimeout,
+// dont la génération a été interrompue (redémarrage, panne). Retourne le nombre d'exports concernés.
+func (s *DataExportService) FailStaleExports() (int64, error) {
+	return s.markInterrupted(s.DB.Where("updated_at < ?", time.Now().Add(-dataExportTimeout)))
+}
+
+// markInterrupted marque en échec les exports en attente ou en cours sélectionnés par query
+// et retourne leur nombre
+func (s *DataExportService) markInterrupted(query *gorm.DB) (int64, error) {
+	res := query.Model(&models.DataExport{}).
+		Where("status IN ?", []models.DataExportStatus{models.ExportPending, models.ExportRunning}).
+		Updates(map[string]interface{}{
+			"status":  models.ExportFailed,
+			"message": dataExportInterrupted,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// isStale indique si un export en attente ou en cours n'a plus progressé depuis dataExportTimeout
+func isStale(export models.DataExport) bool {
+	return (export.Status == models.ExportPending || export.Status == models.ExportRunning) &&
+		time.Since(export.UpdatedAt) > dataExportTimeout
+}
+
+// runExport construit l'archive puis prévient l'utilisateur par email.
+// Une panique pendant la génération marque l'export en échec au lieu de le laisser en cours.
+func (s *DataExportService) runExport(export models.DataExport) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Data export %s panicked: %v", export.ID, r)
+			os.Remove(filepath.Join(s.ExportDir, export.ID+".zip"))
+			if _, err := s.markInterrupted(s.DB.Where("id = ?", export.ID)); err != nil {
+				log.Printf("Failed to update data export %s: %v", export.ID, err)
+			}
+		}
+	}()
+
+	s.DB.Model(&export).Update("status", models.ExportRunning)
+
+	path, size, err := s.buildArchive(export)
+	if err != nil {
+		log.Printf("Failed to build data export %s: %v", export.ID, err)
+		s.DB.Model(&export).Updates(map[string]interface{}{
+			"status":  models.ExportFailed,
+			"message": "failed to build the archive",
+		})
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(DataExportRetention)
+	export.Status = models.ExportCompleted
+	export.FilePath = path
+	export.ExpiresAt = &expiresAt
+	// L'export a pu être marqué comme interrompu entre-temps (FailStaleExports) : l'archive est alors abandonnée
+	res := s.DB.Model(&models.DataExport{}).Where("id = ? AND status = ?", export.ID, models.ExportRunning).Updates(map[string]interface{}{
+		"status":       models.ExportCompleted,
+		"file_path":    path,
+		"size_bytes":   size,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	})
+	if res.Error != nil || res.RowsAffected == 0 {
+		log.Printf("Failed to update data export %s: %v", export.ID, res.Error)
+		os.Remove(path)
+		return
+	}
+
+	var user models.Users
+	if err := s.DB.Select("id", "email").Where("id = ?", export.UserID).First(&user).Error; err != nil {
+		log.Printf("Failed to load user for data export %s: %v", export.ID, err)
+		return
+	}
+	link, _, err := s.DownloadURL(&export)
+	if err != nil {
+		log.Printf("Failed to sign data export link %s: %v", export.ID, err)
+		return
+	}
+	if err := s.EmailService.SendDataExportReadyEmail(user.Email, link, DataExportLinkTTL); err != nil {
+		log.Printf("Error sending data export email: %v", err)
+	}
+}
+
+// buildArchive écrit l'archive zip de l'export et retourne son chemin et sa taille
+func (s *DataExportService) buildArchive(export models.DataExport) (string, int64, error) {
+	if err := os.MkdirAll(s.ExportDir, 0o700); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(s.ExportDir, export.ID+".zip")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := s.writeArchive(zip.NewWriter(file), export.UserID); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", 0, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+// Représentations exportées : champs utiles à l'utilisateur, sans secrets ni empreintes
+
+type exportedProfile struct {
+	ID              string                 `json:"id"`
+	Username        string                 `json:"username"`
+	Email           string                 `json:"email"`
+	PendingEmail    string                 `json:"pending_email,omitempty"`
+	IsConfirmed     bool                   `json:"is_confirmed"`
+	Role            string                 `json:"role"`
+	BirthDate       *time.Time             `json:"birth_date"`
+	ProfilePhoto    string                 `json:"profile_photo"`
+	Location        string                 `json:"location"`
+	Latitude        float64                `json:"latitude"`
+	Longitude       float64                `json:"longitude"`
+	Bio             string                 `json:"bio"`
+	TOTPEnabled     bool                   `json:"totp_enabled"`
+	HasPassword     bool                   `json:"has_password"`
+	HasPushToken    bool                   `json:"has_push_token"`
+	HasCalendarFeed bool                   `json:"has_calendar_feed"`
+	Privacy         models.PrivacySettings `json:"privacy"`
+	Music           models.MusicProfile    `json:"music"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+type exportedFriendRequest struct {
+	ID         uint      `json:"id"`
+	SenderID   string    `json:"sender_id"`
+	ReceiverID string    `json:"receiver_id"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type exportedMessage struct {
+	ID         uint      `json:"id"`
+	SenderID   string    `json:"sender_id"`
+	ReceiverID string    `json:"receiver_id"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type exportedTicket struct {
+	ID                uint       `json:"id"`
+	EventID           uint       `json:"event_id"`
+	PurchaseDate      time.Time  `json:"purchase_date"`
+	SeatNumber        *string    `json:"seat_number"`
+	PriceCents        int64      `json:"price_cents"`
+	Currency          string     `json:"currency"`
+	PaymentStatus     string     `json:"payment_status"`
+	RefundRequestedAt *time.Time `json:"refund_requested_at"`
+	RefundedAt        *time.Time `json:"refunded_at"`
+}
+
+const exportReadme = `Export de vos données personnelles
+
+profile.json          Informations de votre profil
+identities.json       Comptes externes reliés (Google, Apple, GitHub...)
+sessions.json         Appareils connectés à votre compte
+api_keys.json         Clés d'API (les secrets ne sont jamais conservés)
+friend_requests.json  Demandes d'amis envoyées et reçues
+blocked_users.json    Utilisateurs que vous avez bloqués
+messages.json         Messages privés envoyés et reçus
+tickets.json          Billets
+rsvps.json            Participations aux événements
+artist_follows.json   Artistes suivis
+events.json           Événements que vous organisez
+organizer_applications.json
+                      Demandes de statut d'organisateur
+images/               Images et justificatifs que vous avez téléversés
+
+Les notifications sont envoyées en temps réel et ne sont pas conservées sur nos serveurs :
+elles ne figurent donc pas dans cet export.
+`
+
+// writeArchive écrit tous les fichiers de l'export dans l'archive zip
+func (s *DataExportService) writeArchive(zw *zip.Writer, userID string) error {
+	var user models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	if err := writeZipFile(zw, "README.txt", []byte(exportReadme)); err != nil {
+		return err
+	}
+
+	profile := exportedProfile{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		PendingEmail:    user.PendingEmail,
+		IsConfirmed:     user.IsConfirmed,
+		Role:            string(user.Role),
+		BirthDate:       user.BirthDate,
+		ProfilePhoto:    user.ProfilePhoto,
+		Location:        user.Location,
+		Latitude:        user.Latitude,
+		Longitude:       user.Longitude,
+		Bio:             user.Bio,
+		TOTPEnabled:     user.TOTPEnabled,
+		HasPassword:     !user.Passwordless,
+		HasPushToken:    user.FCMToken != "",
+		HasCalendarFeed: user.CalendarTokenHash != "",
+		Privacy:         user.Privacy(),
+		Music:           user.Music(),
+		UpdatedAt:       user.UpdatedAt,
+	}
+	if err := writeZipJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	var identities []models.UserIdentity
+	if err := s.DB.Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "identities.json", identities); err != nil {
+		return err
+	}
+
+	var sessions []models.Session
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "sessions.json", sessions); err != nil {
+		return err
+	}
+
+	var apiKeys []models.APIKey
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at").Find(&apiKeys).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "api_keys.json", apiKeys); err != nil {
+		return err
+	}
+
+	var friendRequests []exportedFriendRequest
+	if err := s.DB.Model(&models.FriendRequest{}).
+		Select("id", "sender_id", "receiver_id", "status", "created_at", "updated_at").
+		Where("sender_id = ? OR receiver_id = ?", userID, userID).
+		Order("created_at").Scan(&friendRequests).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "friend_requests.json", friendRequests); err != nil {
+		return err
+	}
+
+	var blocks []models.UserBlock
+	if err := s.DB.Where("blocker_id = ?", userID).Order("created_at").Find(&blocks).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "blocked_users.json", blocks); err != nil {
+		return err
+	}
+
+	var messages []exportedMessage
+	if err := s.DB.Model(&models.Message{}).
+		Where("sender_id = ? OR receiver_id = ?", userID, userID).
+		Order("created_at").Scan(&messages).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "messages.json", messages); err != nil {
+		return err
+	}
+
+	var tickets []exportedTicket
+	if err := s.DB.Model(&models.Ticket{}).Where("user_id = ?", userID).Order("purchase_date").Scan(&tickets).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "tickets.json", tickets); err != nil {
+		return err
+	}
+
+	var rsvps []models.RSVP
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at").Find(&rsvps).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "rsvps.json", rsvps); err != nil {
+		return err
+	}
+
+	var follows []models.ArtistFollow
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at").Find(&follows).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "artist_follows.json", follows); err != nil {
+		return err
+	}
+
+	var events []models.Event
+	if err := s.DB.Unscoped().Where("organizer_id = ?", userID).Order("created_at").Find(&events).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "events.json", events); err != nil {
+		return err
+	}
+
+	var applications []models.OrganizerApplication
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at").Find(&applications).Error; err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "organizer_applications.json", withDocumentFiles(applications)); err != nil {
+		return err
+	}
+
+	// Images téléversées : photo de profil, galeries des événements organisés et justificatifs des demandes d'organisateur
+	images := []string{user.ProfilePhoto}
+	for _, application := range applications {
+		images = append(images, application.Files...)
+	}
+	for _, event := range events {
+		var gallery []string
+		if err := json.Unmarshal([]byte(event.GalleryImages), &gallery); err == nil {
+			images = append(images, gallery...)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, image := range images {
+		name := filepath.Base(image)
+		// Seules les images stockées localement sont copiées (une URL externe figure déjà dans le profil)
+		if image == "" || strings.Contains(image, "://") || name != image || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if err := s.copyImage(zw, name); err != nil {
+			log.Printf("Skipping image %s in data export: %v", name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// copyImage ajoute une image du dossier de téléversement dans le dossier images/ de l'archive
+func (s *DataExportService) copyImage(zw *zip.Writer, name string) error {
+	src, err := os.Open(filepath.Join(s.ImageService.UploadDir, name))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create("images/" + name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return writeZipFile(zw, name, data)
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
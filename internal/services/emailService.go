@@ -2,9 +2,15 @@ package services
 
 import (
 	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
 	"net/smtp"
+	"net/url"
 	"os"
+	"strings"
 	"text/template"
+	"time"
 )
 
 type EmailService struct{}
@@ -91,7 +97,7 @@ const emailTemplate = `
         <h3>Bienvenue sur TeamUp 💫😁️!</h3>
         <p>Pour continuer l'aventure avec TeamUp⚽️, veuillez confirmer votre compte en cliquant sur le bouton ci-dessous ⌛.</p>
         <div class="button-container">
-           <a href="http://localhost:3003/api/confirm_email?token={{.Token}}" class="button">
+           <a href="{{.ConfirmURL}}" class="button">
                 <span class="button-text">Confirmer mon compte</span>
             </a>
         </div>
@@ -101,9 +107,24 @@ const emailTemplate = `
 `
 
 type EmailData struct {
-	ToEmail string
-	Subject string
-	Token   string
+	ToEmail    string
+	Subject    string
+	Token      string
+	ConfirmURL string
+}
+
+// apiBaseURL retourne l'URL publique de l'API (API_URL), utilisée pour les liens envoyés par email
+func apiBaseURL() string {
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://localhost:3003"
+	}
+	return strings.TrimSuffix(apiURL, "/")
+}
+
+// confirmEmailURL construit le lien de confirmation d'adresse à partir de API_URL
+func confirmEmailURL(token string) string {
+	return apiBaseURL() + "/api/confirm_email?token=" + url.QueryEscape(token)
 }
 
 // SendConfirmationEmail envoie un email de confirmation à un utilisateur avec un lien de confirmation
@@ -118,18 +139,13 @@ type EmailData struct {
 // Retourne:
 // - error: une erreur si l'email n'a pas pu être envoyé, nil sinon
 func (e *EmailService) SendConfirmationEmail(toEmail, token string) error {
-	from := os.Getenv("EMAIL_USER")
-	password := os.Getenv("EMAIL_PASSWORD")
-	host := "smtp.gmail.com"
-	port := "587"
-
-	auth := smtp.PlainAuth("", from, password, host)
 	subject := "Confirmez votre compte"
 
 	data := EmailData{
-		ToEmail: toEmail,
-		Subject: subject,
-		Token:   token,
+		ToEmail:    toEmail,
+		Subject:    subject,
+		Token:      token,
+		ConfirmURL: confirmEmailURL(token),
 	}
 
 	tmpl, err := template.New("email").Parse(emailTemplate)
@@ -142,16 +158,121 @@ func (e *EmailService) SendConfirmationEmail(toEmail, token string) error {
 		return err
 	}
 
-	msg := []byte("To: " + toEmail + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"MIME-version: 1.0;\r\n" +
-		"Content-Type: text/html; charset=\"UTF-8\";\r\n\r\n" +
-		body.String())
+	return e.send(toEmail, subject, body.String())
+}
+
+const notificationEmailTemplate = `
+<!DOCTYPE html>
+<html>
+<body style="margin: 0; padding: 0; background-color: white;">
+    <div style="font-family: Arial, sans-serif; margin: 0 auto; padding: 20px;">
+        <h2>Bonjour {{.ToEmail}},</h2>
+        <h3>{{.Heading}}</h3>
+        {{range .Paragraphs}}<p style="font-size: 18px;">{{.}}</p>
+        {{end}}{{if .ActionURL}}<p><a href="{{.ActionURL}}" style="display: inline-block; padding: 10px 20px; font-size: 18px; font-weight: bold; color: #fff; background-color: #01BF6B; text-decoration: none; border-radius: 5px;">{{.ActionLabel}}</a></p>{{end}}
+    </div>
+</body>
+</html>
+`
 
-	err = smtp.SendMail(host+":"+port, auth, from, []string{toEmail}, msg)
+// NotificationEmail décrit un email transactionnel générique (alerte, mise à jour d'événement...)
+type NotificationEmail struct {
+	ToEmail     string
+	Subject     string
+	Heading     string
+	Paragraphs  []string
+	ActionURL   string
+	ActionLabel string
+}
+
+// SendNotificationEmail envoie un email HTML générique.
+//
+// Contrairement au template de confirmation, le contenu est échappé (html/template),
+// car il peut contenir des données saisies par les utilisateurs (titre d'événement, motif...).
+func (e *EmailService) SendNotificationEmail(email NotificationEmail) error {
+	tmpl, err := htmltemplate.New("notification").Parse(notificationEmailTemplate)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, email); err != nil {
+		return err
+	}
+
+	return e.send(email.ToEmail, email.Subject, body.String())
+}
+
+// SendEmailChangeConfirmation demande de vérifier la nouvelle adresse saisie par l'utilisateur.
+// L'adresse du compte n'est remplacée qu'une fois le lien ouvert.
+func (e *EmailService) SendEmailChangeConfirmation(toEmail, token string, ttl time.Duration) error {
+	return e.SendNotificationEmail(NotificationEmail{
+		ToEmail: toEmail,
+		Subject: "Confirmez votre nouvelle adresse email",
+		Heading: "Changement d'adresse email",
+		Paragraphs: []string{
+			"Vous avez demandé à utiliser cette adresse pour votre compte.",
+			fmt.Sprintf("Ce lien est valable %d heures.", int(ttl.Hours())),
+			"Si vous n'êtes pas à l'origine de cette demande, vous pouvez ignorer cet email.",
+		},
+		ActionURL:   confirmEmailURL(token),
+		ActionLabel: "Confirmer cette adresse",
+	})
+}
+
+// SendPasswordResetEmail envoie le lien de réinitialisation du mot de passe.
+//
+// Le lien pointe vers PASSWORD_RESET_URL (page du front) avec le jeton en paramètre.
+func (e *EmailService) SendPasswordResetEmail(toEmail, token string, ttl time.Duration) error {
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:3003/reset_password"
+	}
+
+	return e.SendNotificationEmail(NotificationEmail{
+		ToEmail: toEmail,
+		Subject: "Réinitialisation de votre mot de passe",
+		Heading: "Mot de passe oublié ?",
+		Paragraphs: []string{
+			"Nous avons reçu une demande de réinitialisation du mot de passe de votre compte.",
+			fmt.Sprintf("Ce lien est valable %d minutes et ne peut être utilisé qu'une seule fois.", int(ttl.Minutes())),
+			"Si vous n'êtes pas à l'origine de cette demande, vous pouvez ignorer cet email.",
+		},
+		ActionURL:   resetURL + "?token=" + url.QueryEscape(token),
+		ActionLabel: "Choisir un nouveau mot de passe",
+	})
+}
+
+// SendDataExportReadyEmail prévient l'utilisateur que l'archive de ses données est prête à être téléchargée
+func (e *EmailService) SendDataExportReadyEmail(toEmail, downloadURL string, ttl time.Duration) error {
+	return e.SendNotificationEmail(NotificationEmail{
+		ToEmail: toEmail,
+		Subject: "Vos données sont prêtes",
+		Heading: "Export de vos données personnelles",
+		Paragraphs: []string{
+			"L'archive contenant les données associées à votre compte est prête.",
+			fmt.Sprintf("Ce lien est valable %d minutes. Passé ce délai, vous pouvez en générer un nouveau depuis l'application.", int(ttl.Minutes())),
+			"Si vous n'êtes pas à l'origine de cette demande, changez votre mot de passe.",
+		},
+		ActionURL:   downloadURL,
+		ActionLabel: "Télécharger mes données",
+	})
+}
+
+// send envoie un email HTML via le serveur SMTP configuré
+func (e *EmailService) send(toEmail, subject, htmlBody string) error {
+	from := os.Getenv("EMAIL_USER")
+	password := os.Getenv("EMAIL_PASSWORD")
+	host := "smtp.gmail.com"
+	port := "587"
+
+	auth := smtp.PlainAuth("", from, password, host)
+
+	msg := []byte("To: " + toEmail + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-version: 1.0;\r\n" +
+		"Content-Type: text/html; charset=\"UTF-8\";\r\n\r\n" +
+		htmlBody)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{toEmail}, msg)
 }
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+// Supported import/export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// MaxImportRows caps the number of events accepted in a single import
+const MaxImportRows = 5000
+
+// importProgressEvery controls how often the job progress is persisted
+const importProgressEvery = 50
+
+// importJobTimeout is how long a pending or running job may go without progress before it is
+// considered interrupted (server restart during the import...)
+const importJobTimeout = 30 * time.Minute
+
+// importJobInterrupted is the message of interrupted jobs
+const importJobInterrupted = "the import was interrupted, please start it again"
+
+// eventCSVColumns is the column order used for CSV export; imports accept them in any order
+var eventCSVColumns = []string{
+	"title", "description", "address", "event_date", "event_time", "end_time",
+	"latitude", "longitude", "artist_id", "category_ids", "rrule", "time_zone",
+}
+
+// EventRow is the flat representation of an event used by imports and exports.
+// Dates are RFC 3339; category_ids is a JSON array or a ";"-separated list.
+type EventRow struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Address     string `json:"address"`
+	EventDate   string `json:"event_date"`
+	EventTime   string `json:"event_time"`
+	EndTime     string `json:"end_time"`
+	Latitude    string `json:"latitude"`
+	Longitude   string `json:"longitude"`
+	ArtistID    string `json:"artist_id"`
+	CategoryIDs string `json:"category_ids"`
+	RRule       string `json:"rrule"`
+	TimeZone    string `json:"time_zone"`
+}
+
+// RowError lists the validation errors of one imported row (1-based, header excluded)
+type RowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+// EventImportService imports and exports events in bulk
+type EventImportService struct {
+	DB           *gorm.DB
+	EventService *EventService
+}
+
+// NewEventImportService creates a new instance of EventImportService
+func NewEventImportService(db *gorm.DB, eventService *EventService) *EventImportService {
+	return &EventImportService{
+		DB:           db,
+		EventService: eventService,
+	}
+}
+
+// StartImport parses the uploaded data, records an import job and processes it in the background.
+// Rows are validated one by one; events are only created if every row is valid and dryRun is false.
+func (s *EventImportService) StartImport(organizerID, format string, data []byte, dryRun bool) (*models.ImportJob, error) {
+	rows, err := ParseEventRows(format, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no rows to import")
+	}
+	if len(rows) > MaxImportRows {
+		return nil, fmt.Errorf("too many rows: %d (max %d)", len(rows), MaxImportRows)
+	}
+
+	entropy := ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
+	job := models.ImportJob{
+		ID:          ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String(),
+		OrganizerID: organizerID,
+		Format:      format,
+		DryRun:      dryRun,
+		Status:      models.ImportPending,
+		TotalRows:   len(rows),
+		Errors:      json.RawMessage("[]"),
+	}
+	if err := s.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	go s.runImport(job, rows)
+
+	return &job, nil
+}
+
+// GetImportJob retrieves an import job belonging to the given organizer
+func (s *EventImportService) GetImportJob(jobID, organizerID string) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.DB.Where("id = ? AND organizer_id = ?", jobID, organizerID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("import job not found")
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FailStaleImports marks as failed the jobs pending or running without progress for more than
+// importJobTimeout, whose processing was interrupted. Returns the number of jobs affected.
+func (s *EventImportService) FailStaleImports() (int64, error) {
+	return s.markInterrupted(s.DB.Where("updated_at < ?", time.Now().Add(-importJobTimeout)))
+}
+
+// markInterrupted marks as failed the pending or running jobs selected by query
+func (s *EventImportService) markInterrupted(query *gorm.DB) (int64, error) {
+	res := query.Model(&models.ImportJob{}).
+		Where("status IN ?", []models.ImportJobStatus{models.ImportPending, models.ImportRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportFailed,
+			"message":     importJobInterrupted,
+			"finished_at": time.Now(),
+		})
+	return res.RowsAffected, res.Error
+}
+
+// runImport validates every row, persisting progress as it goes, then creates the events.
+// A panic while processing the rows fails the job instead of leaving it running.
+func (s *EventImportService) runImport(job models.ImportJob, rows []EventRow) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Import job %s panicked: %v", job.ID, r)
+			if _, err := s.markInterrupted(s.DB.Where("id = ?", job.ID)); err != nil {
+				log.Printf("Failed to update import job %s: %v", job.ID, err)
+			}
+		}
+	}()
+
+	if err := s.DB.Model(&job).Update("status", models.ImportRunning).Error; err != nil {
+		// The job stays pending until FailStaleImports marks it as failed
+		log.Printf("Failed to start import job %s: %v", job.ID, err)
+		return
+	}
+
+	var rowErrors []RowError
+	events := make([]models.Event, 0, len(rows))
+	for i, row := range rows {
+		event, errs := s.validateRow(row, job.OrganizerID)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, RowError{Row: i + 1, Errors: errs})
+		} else {
+			events = append(events, *event)
+		}
+
+		if (i+1)%importProgressEvery == 0 {
+			if err := s.DB.Model(&job).Update("processed_rows", i+1).Error; err != nil {
+				log.Printf("Failed to update progress of import job %s: %v", job.ID, err)
+			}
+		}
+	}
+
+	errorsJSON, err := json.Marshal(rowErrors)
+	if err != nil || rowErrors == nil {
+		errorsJSON = []byte("[]")
+	}
+	updates := map[string]interface{}{
+		"processed_rows": len(rows),
+		"errors":         json.RawMessage(errorsJSON),
+		"finished_at":    time.Now(),
+	}
+
+	switch {
+	case len(rowErrors) > 0:
+		updates["status"] = models.ImportFailed
+		updates["message"] = fmt.Sprintf("%d invalid row(s), nothing was imported", len(rowErrors))
+	case job.DryRun:
+		updates["status"] = models.ImportCompleted
+		updates["message"] = "dry run: all rows are valid, nothing was imported"
+	default:
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(&events, 100).Error
+		})
+		if err != nil {
+			log.Printf("Failed to import events for job %s: %v", job.ID, err)
+			updates["status"] = models.ImportFailed
+			updates["message"] = "failed to save events"
+		} else {
+			updates["status"] = models.ImportCompleted
+			updates["imported_rows"] = len(events)
+			updates["message"] = fmt.Sprintf("%d event(s) imported", len(events))
+		}
+	}
+
+	if err := s.DB.Model(&job).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update import job %s: %v", job.ID, err)
+	}
+}
+
+// validateRow converts a row into an event of organizerID, collecting every validation error
+func (s *EventImportService) validateRow(row EventRow, organizerID string) (*models.Event, []string) {
+	var errs []string
+	event := models.Event{
+		OrganizerID: organizerID,
+		Title:       strings.TrimSpace(row.Title),
+		Description: row.Description,
+		Address:     strings.TrimSpace(row.Address),
+		Status:      models.Upcoming,
+		// Imported events start as drafts, like events created one by one
+		PublicationState: models.StateDraft,
+		Visibility:       models.VisibilityPublic,
+		RRule:            strings.TrimSpace(row.RRule),
+		TimeZone:         strings.TrimSpace(row.TimeZone),
+	}
+
+	if event.Title == "" {
+		errs = append(errs, "title is required")
+	}
+
+	parseTime := func(field, value string, required bool) time.Time {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			if required {
+				errs = append(errs, field+" is required")
+			}
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, field+" must be an RFC 3339 date-time")
+		}
+		return t
+	}
+	event.EventTime = parseTime("event_time", row.EventTime, true)
+	event.EventDate = parseTime("event_date", row.EventDate, false)
+	event.EndTime = parseTime("end_time", row.EndTime, false)
+	if event.EventDate.IsZero() {
+		event.EventDate = event.EventTime
+	}
+	if !event.EndTime.IsZero() && !event.EventTime.IsZero() && !event.EndTime.After(event.EventTime) {
+		errs = append(errs, "end_time must be after event_time")
+	}
+
+	parseFloat := func(field, value string, limit float64) float64 {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < -limit || f > limit {
+			errs = append(errs, fmt.Sprintf("%s must be a number between -%g and %g", field, limit, limit))
+		}
+		return f
+	}
+	event.Latitude = parseFloat("latitude", row.Latitude, 90)
+	event.Longitude = parseFloat("longitude", row.Longitude, 180)
+
+	if v := strings.TrimSpace(row.ArtistID); v != "" {
+		artistID, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, "artist_id must be an integer")
+		} else {
+			var count int64
+			s.DB.Model(&models.Artist{}).Where("artist_id = ?", artistID).Count(&count)
+			if count == 0 {
+				errs = append(errs, fmt.Sprintf("artist %d does not exist", artistID))
+			}
+			event.ArtistID = artistID
+		}
+	}
+
+	categories, err := parseCategoryIDs(row.CategoryIDs)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	event.CategoryIds = categories
+	event.GalleryImages = "[]"
+
+	if err := s.EventService.ValidateRecurrence(&event); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	return &event, errs
+}
+
+// ExportEvents encodes the events of an organizer in the requested format
+func (s *EventImportService) ExportEvents(organizerID, format string) ([]byte, error) {
+	events, err := s.EventService.GetEventsByOrganizerID(organizerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]EventRow, 0, len(events))
+	for _, e := range events {
+		rows = append(rows, eventToRow(e))
+	}
+
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(rows, "", "  ")
+	case FormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.Write(eventCSVColumns); err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			record := []string{r.Title, r.Description, r.Address, r.EventDate, r.EventTime, r.EndTime,
+				r.Latitude, r.Longitude, r.ArtistID, r.CategoryIDs, r.RRule, r.TimeZone}
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// ParseEventRows decodes a CSV (with a header line) or JSON array of events
+func ParseEventRows(format string, data []byte) ([]EventRow, error) {
+	switch format {
+	case FormatJSON:
+		// Numbers are accepted as well as strings for numeric columns
+		var raw []map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		rows := make([]EventRow, 0, len(raw))
+		for _, r := range raw {
+			get := func(key string) string {
+				switch v := r[key].(type) {
+				case nil:
+					return ""
+				case string:
+					return v
+				case []interface{}:
+					b, _ := json.Marshal(v)
+					return string(b)
+				default:
+					return fmt.Sprint(v)
+				}
+			}
+			rows = append(rows, EventRow{
+				Title: get("title"), Description: get("description"), Address: get("address"),
+				EventDate: get("event_date"), EventTime: get("event_time"), EndTime: get("end_time"),
+				Latitude: get("latitude"), Longitude: get("longitude"), ArtistID: get("artist_id"),
+				CategoryIDs: get("category_ids"), RRule: get("rrule"), TimeZone: get("time_zone"),
+			})
+		}
+		return rows, nil
+	case FormatCSV:
+		r := csv.NewReader(bytes.NewReader(data))
+		r.TrimLeadingSpace = true
+		header, err := r.Read()
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV header: %w", err)
+		}
+		index := map[string]int{}
+		for i, h := range header {
+			index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+		}
+		if _, ok := index["title"]; !ok {
+			return nil, errors.New("CSV header must contain a title column")
+		}
+
+		var rows []EventRow
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV: %w", err)
+			}
+			get := func(key string) string {
+				if i, ok := index[key]; ok && i < len(record) {
+					return record[i]
+				}
+				return ""
+			}
+			rows = append(rows, EventRow{
+				Title: get("title"), Description: get("description"), Address: get("address"),
+				EventDate: get("event_date"), EventTime: get("event_time"), EndTime: get("end_time"),
+				Latitude: get("latitude"), Longitude: get("longitude"), ArtistID: get("artist_id"),
+				CategoryIDs: get("category_ids"), RRule: get("rrule"), TimeZone: get("time_zone"),
+			})
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// parseCategoryIDs normalizes category ids into the JSON array stored on events
+func parseCategoryIDs(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "[]", nil
+	}
+
+	var ids []int
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &ids); err != nil {
+			return "[]", errors.New("category_ids must be a list of integers")
+		}
+	} else {
+		for _, part := range strings.Split(value, ";") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return "[]", errors.New("category_ids must be a list of integers")
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return "[]", err
+	}
+	return string(b), nil
+}
+
+func eventToRow(e models.Event) EventRow {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	row := EventRow{
+		Title:       e.Title,
+		Description: e.Description,
+		Address:     e.Address,
+		EventDate:   formatTime(e.EventDate),
+		EventTime:   formatTime(e.EventTime),
+		EndTime:     formatTime(e.EndTime),
+		Latitude:    strconv.FormatFloat(e.Latitude, 'f', -1, 64),
+		Longitude:   strconv.FormatFloat(e.Longitude, 'f', -1, 64),
+		CategoryIDs: e.CategoryIds,
+		RRule:       e.RRule,
+		TimeZone:    e.TimeZone,
+	}
+	if e.ArtistID != 0 {
+		row.ArtistID = strconv.Itoa(e.ArtistID)
+	}
+	return row
+}
@@ -25,6 +25,14 @@ func NewFriendChatService(db *gorm.DB, webSocketService *WebSocketService) *Frie
 func (s *FriendChatService) SendMessage(senderID, receiverID, content string) error {
 	log.Printf("Sending message from %s to %s: %s", senderID, receiverID, content)
 
+	blocked, err := isBlocked(s.DB, senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("failed to check block status: %w", err)
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+
 	// Create the message data
 	message := models.Message{
 		SenderID:   senderID,
@@ -55,8 +63,8 @@ func (s *FriendChatService) SendMessage(senderID, receiverID, content string) er
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	// Send the notification via WebSocketService
-	s.WebSocketService.broadcast <- notificationData
+	// Send the notification to the receiver only
+	s.WebSocketService.SendToUser(receiverID, notificationData)
 
 	return nil
 }
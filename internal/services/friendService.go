@@ -2,12 +2,32 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/mackenzii/freemusic/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// uniqueViolation is the PostgreSQL error code raised when a unique index rejects a row
+const uniqueViolation = "23505"
+
+// friendRequestCooldown is how long a user must wait before asking again someone who declined their request
+const friendRequestCooldown = 7 * 24 * time.Hour
+
+var (
+	ErrUserBlocked           = errors.New("this user is not available")
+	ErrCannotBlockSelf       = errors.New("you cannot block yourself")
+	ErrNotBlocked            = errors.New("user is not blocked")
+	ErrAlreadyFriends        = errors.New("users are already friends")
+	ErrNotFriends            = errors.New("users are not friends")
+	ErrFriendRequestPending  = errors.New("friend request already pending")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrFriendRequestCooldown = errors.New("friend request was declined recently")
 )
 
 type FriendService struct {
@@ -28,11 +48,32 @@ func NewFriendService(db *gorm.DB, authService *AuthService, webSocketService *W
 func (s *FriendService) SendFriendRequest(senderId, receiverId string) error {
 	log.Printf("Sending friend request from %s to %s", senderId, receiverId)
 
-	// Check if the friend request already exists in the database
+	blocked, err := isBlocked(s.DB, senderId, receiverId)
+	if err != nil {
+		return fmt.Errorf("failed to check block status: %w", err)
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+
+	// A pair has a single row: reuse it when an earlier request was declined
 	var existingRequest models.FriendRequest
-	if err := s.DB.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", senderId, receiverId, receiverId, senderId).First(&existingRequest).Error; err == nil {
-		log.Printf("Friend request already exists in database for %s to %s", senderId, receiverId)
-		return fmt.Errorf("friend request already exists")
+	err = s.DB.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", senderId, receiverId, receiverId, senderId).First(&existingRequest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to check existing friend request: %w", err)
+	}
+	exists := err == nil
+	if exists {
+		switch {
+		case existingRequest.Status == "accepted":
+			return ErrAlreadyFriends
+		case existingRequest.Status == "pending":
+			log.Printf("Friend request already pending between %s and %s", senderId, receiverId)
+			return ErrFriendRequestPending
+		case existingRequest.SenderId == senderId && time.Since(existingRequest.UpdatedAt) < friendRequestCooldown:
+			return fmt.Errorf("%w, try again after %s", ErrFriendRequestCooldown,
+				existingRequest.UpdatedAt.Add(friendRequestCooldown).Format(time.RFC3339))
+		}
 	}
 
 	// Create the friend request data
@@ -42,9 +83,18 @@ func (s *FriendService) SendFriendRequest(senderId, receiverId string) error {
 		Status:     "pending",
 		CreatedAt:  time.Now(),
 	}
+	if exists {
+		friendRequest.ID = existingRequest.ID
+	}
 
-	// Store the friend request in the database
-	if err := s.DB.Create(&friendRequest).Error; err != nil {
+	// Store the friend request in the database.
+	// A concurrent request for the same pair loses on idx_friend_requests_pair.
+	if err := s.DB.Save(&friendRequest).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			log.Printf("Friend request already pending between %s and %s", senderId, receiverId)
+			return ErrFriendRequestPending
+		}
 		log.Printf("Failed to create friend request in database: %v", err)
 		return fmt.Errorf("failed to create friend request in database: %w", err)
 	}
@@ -64,8 +114,8 @@ func (s *FriendService) SendFriendRequest(senderId, receiverId string) error {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	// Send the notification via WebSocketService
-	s.WebSocketService.broadcast <- notificationData
+	// Send the notification to the receiver only
+	s.WebSocketService.SendToUser(receiverId, notificationData)
 
 	return nil
 }
@@ -74,11 +124,19 @@ func (s *FriendService) SendFriendRequest(senderId, receiverId string) error {
 func (s *FriendService) AcceptFriendRequest(senderId, receiverId string) error {
 	log.Printf("Accepting friend request from %s to %s", senderId, receiverId)
 
+	blocked, err := isBlocked(s.DB, senderId, receiverId)
+	if err != nil {
+		return fmt.Errorf("failed to check block status: %w", err)
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+
 	// Check if the friend request exists in the database
 	var friendRequest models.FriendRequest
-	if err := s.DB.Where("sender_id = ? AND receiver_id = ?", senderId, receiverId).First(&friendRequest).Error; err != nil {
+	if err := s.DB.Where("sender_id = ? AND receiver_id = ? AND status = ?", senderId, receiverId, "pending").First(&friendRequest).Error; err != nil {
 		log.Printf("Friend request not found for %s to %s: %v", senderId, receiverId, err)
-		return fmt.Errorf("friend request not found: %w", err)
+		return ErrFriendRequestNotFound
 	}
 
 	log.Printf("Friend request found: %v", friendRequest)
@@ -106,8 +164,8 @@ func (s *FriendService) AcceptFriendRequest(senderId, receiverId string) error {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	// Send the notification via WebSocketService
-	s.WebSocketService.broadcast <- notificationData
+	// Send the notification to the sender of the request only
+	s.WebSocketService.SendToUser(senderId, notificationData)
 
 	return nil
 }
@@ -118,9 +176,9 @@ func (s *FriendService) DeclineFriendRequest(senderId, receiverId string) error
 
 	// Check if the friend request exists in the database
 	var friendRequest models.FriendRequest
-	if err := s.DB.Where("sender_id = ? AND receiver_id = ?", senderId, receiverId).First(&friendRequest).Error; err != nil {
+	if err := s.DB.Where("sender_id = ? AND receiver_id = ? AND status = ?", senderId, receiverId, "pending").First(&friendRequest).Error; err != nil {
 		log.Printf("Friend request not found for %s to %s: %v", senderId, receiverId, err)
-		return fmt.Errorf("friend request not found: %w", err)
+		return ErrFriendRequestNotFound
 	}
 
 	log.Printf("Friend request found: %v", friendRequest)
@@ -148,33 +206,116 @@ func (s *FriendService) DeclineFriendRequest(senderId, receiverId string) error
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	// Send the notification via WebSocketService
-	s.WebSocketService.broadcast <- notificationData
+	// Send the notification to the sender of the request only
+	s.WebSocketService.SendToUser(senderId, notificationData)
 
 	return nil
 }
 
-// GetFriendRequests retrieves the pending friend requests for a user
-func (s *FriendService) GetFriendRequests(userID string) ([]models.FriendRequest, error) {
+// CancelFriendRequest withdraws a pending friend request sent by senderId
+func (s *FriendService) CancelFriendRequest(senderId, receiverId string) error {
+	log.Printf("Cancelling friend request from %s to %s", senderId, receiverId)
+
+	res := s.DB.Where("sender_id = ? AND receiver_id = ? AND status = ?", senderId, receiverId, "pending").Delete(&models.FriendRequest{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to cancel friend request: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrFriendRequestNotFound
+	}
+
+	// Let the receiver drop the request from their list
+	notification := map[string]string{
+		"type":       "friend_request_cancelled",
+		"senderId":   senderId,
+		"receiverId": receiverId,
+	}
+	notificationData, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("Failed to marshal notification: %v", err)
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	s.WebSocketService.SendToUser(receiverId, notificationData)
+
+	return nil
+}
+
+// Unfriend ends the friendship between userID and friendID. Either of them can send a new
+// request right away.
+func (s *FriendService) Unfriend(userID, friendID string) error {
+	log.Printf("Removing friendship between %s and %s", userID, friendID)
+
+	res := s.DB.Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND status = ?",
+		userID, friendID, friendID, userID, "accepted").Delete(&models.FriendRequest{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to remove friendship: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFriends
+	}
+	return nil
+}
+
+// GetOutgoingFriendRequests retrieves the pending friend requests sent by a user.
+// Sender and receiver are exposed as public profiles, as seen by viewerID.
+func (s *FriendService) GetOutgoingFriendRequests(viewerID, userID string) ([]models.FriendRequest, error) {
+	log.Printf("Retrieving outgoing friend requests for user %s", userID)
+	var friendRequests []models.FriendRequest
+	err := s.DB.Preload("Sender", withMusicProfile).Preload("Receiver", withMusicProfile).
+		Where("sender_id = ? AND status = ?", userID, "pending").Order("created_at DESC").Find(&friendRequests).Error
+	if err != nil {
+		log.Printf("Failed to get outgoing friend requests from database: %v", err)
+		return nil, fmt.Errorf("failed to get outgoing friend requests from database: %w", err)
+	}
+	if err := s.attachProfiles(viewerID, friendRequests); err != nil {
+		return nil, fmt.Errorf("failed to build friend request profiles: %w", err)
+	}
+	return friendRequests, nil
+}
+
+// GetFriendRequests retrieves the pending friend requests for a user.
+// Sender and receiver are exposed as public profiles, as seen by viewerID.
+func (s *FriendService) GetFriendRequests(viewerID, userID string) ([]models.FriendRequest, error) {
 	log.Printf("Retrieving friend requests for user %s", userID)
 	var friendRequests []models.FriendRequest
-	err := s.DB.Preload("Sender").Preload("Receiver").Where("receiver_id = ? AND status = ?", userID, "pending").Find(&friendRequests).Error
+	err := s.DB.Preload("Sender", withMusicProfile).Preload("Receiver", withMusicProfile).Where("receiver_id = ? AND status = ?", userID, "pending").Find(&friendRequests).Error
 	if err != nil {
 		log.Printf("Failed to get friend requests from database: %v", err)
 		return nil, fmt.Errorf("failed to get friend requests from database: %w", err)
 	}
+	if err := s.attachProfiles(viewerID, friendRequests); err != nil {
+		return nil, fmt.Errorf("failed to build friend request profiles: %w", err)
+	}
 	log.Printf("Retrieved %d friend requests for user %s", len(friendRequests), userID)
 	return friendRequests, nil
 }
 
-// GetFriends retrieves the list of friends for a user
-func (s *FriendService) GetFriends(userID string) ([]models.Users, error) {
+// attachProfiles fills the public sender/receiver profiles of preloaded friend requests
+func (s *FriendService) attachProfiles(viewerID string, friendRequests []models.FriendRequest) error {
+	users := make([]models.Users, 0, 2*len(friendRequests))
+	for _, request := range friendRequests {
+		users = append(users, request.Sender, request.Receiver)
+	}
+
+	profiles, err := s.AuthService.PublicProfiles(viewerID, users)
+	if err != nil {
+		return err
+	}
+	for i := range friendRequests {
+		friendRequests[i].SenderProfile = &profiles[2*i]
+		friendRequests[i].ReceiverProfile = &profiles[2*i+1]
+	}
+	return nil
+}
+
+// GetFriends retrieves the list of friends for a user, as public profiles seen by viewerID
+func (s *FriendService) GetFriends(viewerID, userID string) ([]models.PublicUser, error) {
 	log.Printf("Retrieving friends for user %s", userID)
 	var friends []models.Users
 
 	// Retrieve friends where the user is the sender
-	err := s.DB.Joins("JOIN friend_requests ON friend_requests.receiver_id = users.id").
-		Where("friend_requests.sender_id = ? AND friend_requests.status = ?", userID, "accepted").
+	err := s.DB.Scopes(withMusicProfile).Joins("JOIN friend_requests ON friend_requests.receiver_id = users.id").
+		Where("friend_requests.sender_id = ? AND friend_requests.status = ? AND users.deleted_at IS NULL", userID, "accepted").
 		Find(&friends).Error
 	if err != nil {
 		log.Printf("Failed to get friends from database where user is sender: %v", err)
@@ -183,8 +324,8 @@ func (s *FriendService) GetFriends(userID string) ([]models.Users, error) {
 
 	// Retrieve friends where the user is the receiver
 	var friendsAsReceiver []models.Users
-	err = s.DB.Joins("JOIN friend_requests ON friend_requests.sender_id = users.id").
-		Where("friend_requests.receiver_id = ? AND friend_requests.status = ?", userID, "accepted").
+	err = s.DB.Scopes(withMusicProfile).Joins("JOIN friend_requests ON friend_requests.sender_id = users.id").
+		Where("friend_requests.receiver_id = ? AND friend_requests.status = ? AND users.deleted_at IS NULL", userID, "accepted").
 		Find(&friendsAsReceiver).Error
 	if err != nil {
 		log.Printf("Failed to get friends from database where user is receiver: %v", err)
@@ -195,21 +336,22 @@ func (s *FriendService) GetFriends(userID string) ([]models.Users, error) {
 	friends = append(friends, friendsAsReceiver...)
 
 	log.Printf("Retrieved %d friends for user %s", len(friends), userID)
-	return friends, nil
+	return s.AuthService.PublicProfiles(viewerID, friends)
 }
 
-// SearchUsersByUsername searches for users by their username
-func (s *FriendService) SearchUsersByUsername(username string) ([]models.Users, error) {
+// SearchUsersByUsername searches for users by their username, as public profiles seen by viewerID
+func (s *FriendService) SearchUsersByUsername(viewerID, username string) ([]models.PublicUser, error) {
 	log.Printf("Searching users by username: %s", username)
 	var users []models.Users
 	query := "%" + username + "%"
-	err := s.DB.Where("username LIKE ?", query).Find(&users).Error
+	err := s.DB.Scopes(withMusicProfile, notBlockedWith(viewerID, "users.id")).
+		Where("username LIKE ? AND deleted_at IS NULL", query).Find(&users).Error
 	if err != nil {
 		log.Printf("Failed to search users by username: %v", err)
 		return nil, fmt.Errorf("failed to search users by username: %w", err)
 	}
-	log.Printf("Search query: %s, Results: %v", query, users)
-	return users, nil
+	log.Printf("Search query: %s, Results: %d", query, len(users))
+	return s.AuthService.PublicProfiles(viewerID, users)
 }
 
 func (s *FriendService) AreFriends(userID1, userID2 string) (bool, error) {
@@ -223,3 +365,122 @@ func (s *FriendService) AreFriends(userID1, userID2 string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// BlockedUser is an entry of a user's block list
+type BlockedUser struct {
+	models.PublicUser
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockUser adds blockedID to the block list of blockerID and removes any friendship or
+// pending friend request between them. Blocking an already blocked user is a no-op.
+func (s *FriendService) BlockUser(blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+	var count int64
+	if err := s.DB.Model(&models.Users{}).Where("id = ? AND deleted_at IS NULL", blockedID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		block := models.UserBlock{BlockerID: blockerID, BlockedID: blockedID, CreatedAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+			return err
+		}
+		return tx.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
+			blockerID, blockedID, blockedID, blockerID).Delete(&models.FriendRequest{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	log.Printf("User %s blocked %s", blockerID, blockedID)
+	return nil
+}
+
+// UnblockUser removes blockedID from the block list of blockerID. The friendship removed
+// when blocking is not restored.
+func (s *FriendService) UnblockUser(blockerID, blockedID string) error {
+	res := s.DB.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Delete(&models.UserBlock{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to unblock user: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotBlocked
+	}
+	log.Printf("User %s unblocked %s", blockerID, blockedID)
+	return nil
+}
+
+// GetBlockedUsers lists the users blocked by userID, most recent first
+func (s *FriendService) GetBlockedUsers(userID string) ([]BlockedUser, error) {
+	var blocks []models.UserBlock
+	if err := s.DB.Where("blocker_id = ?", userID).Order("created_at DESC").Find(&blocks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blocked users: %w", err)
+	}
+	if len(blocks) == 0 {
+		return []BlockedUser{}, nil
+	}
+
+	ids := make([]string, len(blocks))
+	for i, block := range blocks {
+		ids[i] = block.BlockedID
+	}
+	var users []models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id IN ? AND deleted_at IS NULL", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blocked users: %w", err)
+	}
+	profiles, err := s.AuthService.PublicProfiles(userID, users)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.PublicUser, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.ID] = profile
+	}
+
+	blocked := make([]BlockedUser, 0, len(blocks))
+	for _, block := range blocks {
+		if profile, ok := byID[block.BlockedID]; ok {
+			blocked = append(blocked, BlockedUser{PublicUser: profile, BlockedAt: block.CreatedAt})
+		}
+	}
+	return blocked, nil
+}
+
+// isBlocked reports whether either user has blocked the other
+func isBlocked(db *gorm.DB, userID1, userID2 string) (bool, error) {
+	var count int64
+	err := db.Model(&models.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userID1, userID2, userID2, userID1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// blockedWith returns the IDs of the users who blocked userID or were blocked by them
+func blockedWith(db *gorm.DB, userID string) (map[string]bool, error) {
+	var blocks []models.UserBlock
+	if err := db.Where("blocker_id = ? OR blocked_id = ?", userID, userID).Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		if block.BlockerID == userID {
+			ids[block.BlockedID] = true
+		} else {
+			ids[block.BlockerID] = true
+		}
+	}
+	return ids, nil
+}
+
+// notBlockedWith excludes the rows whose column references a user blocking, or blocked by, userID
+func notBlockedWith(userID, column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(column+" NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)", userID).
+			Where(column+" NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = ?)", userID)
+	}
+}
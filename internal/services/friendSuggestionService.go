@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mackenzii/freemusic/helpers"
+	"github.com/mackenzii/freemusic/internal/models"
+)
+
+const (
+	suggestionDefaultLimit = 20
+	suggestionMaxLimit     = 50
+	suggestionRadiusKm     = 50.0
+	suggestionNearbyLimit  = 500
+
+	// Weight of each signal in the suggestion score
+	mutualFriendWeight  = 3.0
+	sharedEventWeight   = 2.0
+	sharedArtistWeight  = 1.0
+	proximityMaxWeight  = 2.0
+	kmPerLatitudeDegree = 111.0
+)
+
+// FriendSuggestion is a user the viewer may know, with the reasons behind the suggestion
+type FriendSuggestion struct {
+	User          models.PublicUser `json:"user"`
+	Score         float64           `json:"score"`
+	MutualFriends int               `json:"mutual_friends"`
+	SharedEvents  int               `json:"shared_events"`
+	SharedArtists int               `json:"shared_artists"`
+	DistanceKm    *float64          `json:"distance_km,omitempty"`
+	Reasons       []string          `json:"reasons"` // ex: "3 amis en commun"
+}
+
+// suggestionCount is the number of items (friends, events, artists) a candidate shares with the viewer
+type suggestionCount struct {
+	UserID string
+	Count  int
+}
+
+// suggestionCandidate holds the signals gathered for a candidate
+type suggestionCandidate struct {
+	FriendSuggestion
+	ID string
+}
+
+// friendPairs lists each accepted friendship in both directions, as (user_id, friend_id)
+const friendPairs = `SELECT sender_id AS user_id, receiver_id AS friend_id FROM friend_requests WHERE status = 'accepted'
+	UNION ALL SELECT receiver_id, sender_id FROM friend_requests WHERE status = 'accepted'`
+
+// eventAttendance lists the events each user attends, through a "going" RSVP or a ticket
+const eventAttendance = `SELECT user_id, event_id FROM rsvps WHERE status = 'going'
+	UNION SELECT user_id, event_id FROM tickets`
+
+// GetFriendSuggestions ranks the users userID may want to befriend, by mutual friends, events attended
+// together, favorite artists in common and proximity. Friends, users with a pending or declined request
+// with userID, and blocked users in either direction are never suggested.
+// Proximity only uses locations visible to everyone.
+func (s *FriendService) GetFriendSuggestions(userID string, limit, offset int) ([]FriendSuggestion, int, error) {
+	if limit <= 0 {
+		limit = suggestionDefaultLimit
+	}
+	if limit > suggestionMaxLimit {
+		limit = suggestionMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var viewer models.Users
+	if err := s.DB.Where("id = ? AND deleted_at IS NULL", userID).First(&viewer).Error; err != nil {
+		return nil, 0, ErrUserNotFound
+	}
+
+	excluded, err := s.suggestionExclusions(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	candidates := make(map[string]*suggestionCandidate)
+	candidate := func(id string) *suggestionCandidate {
+		c, ok := candidates[id]
+		if !ok {
+			c = &suggestionCandidate{ID: id}
+			candidates[id] = c
+		}
+		return c
+	}
+
+	var mutual []suggestionCount
+	if err := s.DB.Raw(`SELECT p2.friend_id AS user_id, COUNT(*) AS count
+		FROM (`+friendPairs+`) p1 JOIN (`+friendPairs+`) p2 ON p2.user_id = p1.friend_id
+		WHERE p1.user_id = ? AND p2.friend_id <> ?
+		GROUP BY p2.friend_id`, userID, userID).Scan(&mutual).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count mutual friends: %w", err)
+	}
+	for _, row := range mutual {
+		if !excluded[row.UserID] {
+			candidate(row.UserID).MutualFriends = row.Count
+		}
+	}
+
+	var events []suggestionCount
+	if err := s.DB.Raw(`SELECT a2.user_id, COUNT(DISTINCT a2.event_id) AS count
+		FROM (`+eventAttendance+`) a1 JOIN (`+eventAttendance+`) a2 ON a2.event_id = a1.event_id
+		WHERE a1.user_id = ? AND a2.user_id <> ?
+		GROUP BY a2.user_id`, userID, userID).Scan(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count shared events: %w", err)
+	}
+	for _, row := range events {
+		if !excluded[row.UserID] {
+			candidate(row.UserID).SharedEvents = row.Count
+		}
+	}
+
+	var artists []suggestionCount
+	if err := s.DB.Raw(`SELECT b.user_id, COUNT(*) AS count
+		FROM user_favorite_artists a JOIN user_favorite_artists b ON b.artist_id = a.artist_id
+		WHERE a.user_id = ? AND b.user_id <> ?
+		GROUP BY b.user_id`, userID, userID).Scan(&artists).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count shared artists: %w", err)
+	}
+	for _, row := range artists {
+		if !excluded[row.UserID] {
+			candidate(row.UserID).SharedArtists = row.Count
+		}
+	}
+
+	hasLocation := viewer.Latitude != 0 || viewer.Longitude != 0
+	if hasLocation {
+		var nearby []string
+		latDelta := suggestionRadiusKm / kmPerLatitudeDegree
+		lonDelta := suggestionRadiusKm / (kmPerLatitudeDegree * math.Max(math.Cos(viewer.Latitude*math.Pi/180), 0.01))
+		if err := s.DB.Model(&models.Users{}).
+			Where("id <> ? AND deleted_at IS NULL AND location_visibility = ?", userID, models.ProfileVisibleToEveryone).
+			Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?",
+				viewer.Latitude-latDelta, viewer.Latitude+latDelta, viewer.Longitude-lonDelta, viewer.Longitude+lonDelta).
+			Limit(suggestionNearbyLimit).Pluck("id", &nearby).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to find nearby users: %w", err)
+		}
+		for _, id := range nearby {
+			if !excluded[id] {
+				candidate(id)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return []FriendSuggestion{}, 0, nil
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	var users []models.Users
+	if err := s.DB.Select("id", "username", "latitude", "longitude", "location_visibility").
+		Where("id IN ? AND deleted_at IS NULL", ids).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load suggested users: %w", err)
+	}
+
+	ranked := make([]*suggestionCandidate, 0, len(users))
+	usernames := make(map[string]string, len(users))
+	for _, user := range users {
+		c := candidates[user.ID]
+		usernames[user.ID] = user.Username
+		c.Score = mutualFriendWeight*float64(c.MutualFriends) +
+			sharedEventWeight*float64(c.SharedEvents) +
+			sharedArtistWeight*float64(c.SharedArtists)
+		if hasLocation && (user.Latitude != 0 || user.Longitude != 0) && user.LocationVisibility == models.ProfileVisibleToEveryone {
+			distance := helpers.DistanceKm(viewer.Latitude, viewer.Longitude, user.Latitude, user.Longitude)
+			if distance <= suggestionRadiusKm {
+				rounded := math.Round(distance*10) / 10
+				c.DistanceKm = &rounded
+				c.Score += proximityMaxWeight * (1 - distance/suggestionRadiusKm)
+			}
+		}
+		if c.Score > 0 {
+			ranked = append(ranked, c)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return usernames[ranked[i].ID] < usernames[ranked[j].ID]
+	})
+
+	total := len(ranked)
+	if offset >= total {
+		return []FriendSuggestion{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	page := ranked[offset:end]
+
+	pageIDs := make([]string, len(page))
+	for i, c := range page {
+		pageIDs[i] = c.ID
+	}
+	var pageUsers []models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id IN ?", pageIDs).Find(&pageUsers).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load suggested users: %w", err)
+	}
+	profiles, err := s.AuthService.PublicProfiles(userID, pageUsers)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]models.PublicUser, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.ID] = profile
+	}
+
+	suggestions := make([]FriendSuggestion, 0, len(page))
+	for _, c := range page {
+		suggestion := c.FriendSuggestion
+		suggestion.User = byID[c.ID]
+		suggestion.Score = math.Round(c.Score*100) / 100
+		suggestion.Reasons = suggestionReasons(suggestion)
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, total, nil
+}
+
+// suggestionExclusions returns the users never suggested to userID: users with any friend request
+// row shared with userID (friends, pending or declined requests) and blocked users in either direction
+func (s *FriendService) suggestionExclusions(userID string) (map[string]bool, error) {
+	excluded, err := blockedWith(s.DB, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load block list: %w", err)
+	}
+
+	var requests []models.FriendRequest
+	if err := s.DB.Select("sender_id", "receiver_id").
+		Where("sender_id = ? OR receiver_id = ?", userID, userID).Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to load friend requests: %w", err)
+	}
+	for _, request := range requests {
+		excluded[request.SenderId] = true
+		excluded[request.ReceiverId] = true
+	}
+	excluded[userID] = true
+	return excluded, nil
+}
+
+// suggestionReasons explains a suggestion to the viewer, strongest signal first
+func suggestionReasons(suggestion FriendSuggestion) []string {
+	reasons := []string{}
+	if suggestion.MutualFriends > 0 {
+		reasons = append(reasons, plural(suggestion.MutualFriends, "ami en commun", "amis en commun"))
+	}
+	if suggestion.SharedEvents > 0 {
+		reasons = append(reasons, plural(suggestion.SharedEvents, "événement en commun", "événements en commun"))
+	}
+	if suggestion.SharedArtists > 0 {
+		reasons = append(reasons, plural(suggestion.SharedArtists, "artiste favori en commun", "artistes favoris en commun"))
+	}
+	if suggestion.DistanceKm != nil {
+		if *suggestion.DistanceKm < 1 {
+			reasons = append(reasons, "À moins d'1 km")
+		} else {
+			reasons = append(reasons, fmt.Sprintf("À %.0f km", *suggestion.DistanceKm))
+		}
+	}
+	return reasons
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mackenzii/freemusic/helpers"
+)
+
+// Paramètres de la protection contre le brute-force sur /api/login
+const (
+	loginFailureWindow     = 15 * time.Minute // Durée de conservation des échecs
+	loginAccountThreshold  = 5                // Échecs tolérés par compte avant blocage
+	loginIPThreshold       = 20               // Échecs tolérés par IP avant blocage
+	loginBaseLockout       = time.Minute      // Premier blocage, doublé à chaque nouvel échec
+	loginMaxLockout        = time.Hour
+	loginGuardKeyPrefix    = "login_guard:"
+	loginGuardAccountScope = "account"
+	loginGuardIPScope      = "ip"
+)
+
+// LockedError est retournée tant qu'un compte ou une IP est temporairement bloqué
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %d seconds", int(e.RetryAfter.Seconds())+1)
+}
+
+// LoginGuardService compte les échecs de connexion par compte et par IP dans Redis
+// et applique un blocage temporaire à durée exponentielle.
+//
+// Les compteurs de compte sont indexés par l'empreinte de l'email saisi, et non par l'ID
+// de l'utilisateur : une adresse inconnue est bloquée exactement comme une adresse inscrite.
+type LoginGuardService struct {
+	redisClient *redis.Client
+}
+
+func NewLoginGuardService(redisClient *redis.Client) *LoginGuardService {
+	return &LoginGuardService{redisClient: redisClient}
+}
+
+// Check retourne une LockedError si le compte ou l'IP est actuellement bloqué.
+// Une indisponibilité de Redis n'empêche pas la connexion (l'erreur est journalisée).
+func (g *LoginGuardService) Check(email, ip string) error {
+	ctx := context.Background()
+	var retryAfter time.Duration
+	for _, key := range []string{g.lockKey(loginGuardAccountScope, email), g.lockKey(loginGuardIPScope, ip)} {
+		ttl, err := g.redisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			log.Printf("Login guard unavailable: %v", err)
+			return nil
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RegisterFailure enregistre un échec et bloque le compte et/ou l'IP au-delà des seuils.
+// Retourne vrai lorsque le compte vient d'atteindre son seuil (pour prévenir son propriétaire).
+func (g *LoginGuardService) RegisterFailure(email, ip string) bool {
+	accountLocked := g.registerFailure(loginGuardAccountScope, email, loginAccountThreshold)
+	g.registerFailure(loginGuardIPScope, ip, loginIPThreshold)
+	return accountLocked
+}
+
+// Reset efface les échecs et le blocage d'un compte (connexion réussie ou déblocage par un admin).
+// Les compteurs par IP ne sont pas remis à zéro, pour qu'une connexion valide ne masque pas une attaque.
+func (g *LoginGuardService) Reset(email string) error {
+	ctx := context.Background()
+	return g.redisClient.Del(ctx,
+		g.failureKey(loginGuardAccountScope, email),
+		g.lockKey(loginGuardAccountScope, email),
+	).Err()
+}
+
+// registerFailure incrémente le compteur d'une portée et pose le verrou si nécessaire.
+// Retourne vrai si le seuil vient d'être atteint.
+func (g *LoginGuardService) registerFailure(scope, subject string, threshold int64) bool {
+	ctx := context.Background()
+	failureKey := g.failureKey(scope, subject)
+
+	pipe := g.redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, failureKey)
+	pipe.Expire(ctx, failureKey, loginFailureWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Login guard unavailable: %v", err)
+		return false
+	}
+
+	failures := incr.Val()
+	if failures < threshold {
+		return false
+	}
+
+	if err := g.redisClient.Set(ctx, g.lockKey(scope, subject), "1", lockoutDuration(failures-threshold)).Err(); err != nil {
+		log.Printf("Login guard unavailable: %v", err)
+	}
+	return failures == threshold
+}
+
+// lockoutDuration double la durée de blocage à chaque échec au-delà du seuil
+func lockoutDuration(excess int64) time.Duration {
+	d := loginBaseLockout
+	for i := int64(0); i < excess && d < loginMaxLockout; i++ {
+		d *= 2
+	}
+	if d > loginMaxLockout {
+		d = loginMaxLockout
+	}
+	return d
+}
+
+func (g *LoginGuardService) failureKey(scope, subject string) string {
+	return loginGuardKeyPrefix + "failures:" + scope + ":" + normalizeGuardSubject(scope, subject)
+}
+
+func (g *LoginGuardService) lockKey(scope, subject string) string {
+	return loginGuardKeyPrefix + "lock:" + scope + ":" + normalizeGuardSubject(scope, subject)
+}
+
+// normalizeGuardSubject évite de stocker les adresses email en clair dans Redis
+func normalizeGuardSubject(scope, subject string) string {
+	if scope == loginGuardAccountScope {
+		return helpers.HashToken(strings.ToLower(strings.TrimSpace(subject)))
+	}
+	return subject
+}
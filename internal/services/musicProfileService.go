@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxFavoriteGenres       = 20
+	maxFavoriteArtists      = 50
+	maxInstruments          = 10
+	maxInstrumentLength     = 50
+	maxListeningPreferences = 1000
+)
+
+var (
+	ErrUnknownGenre          = errors.New("unknown genre")
+	ErrUnknownArtist         = errors.New("unknown artist")
+	ErrTooManyGenres         = errors.New("too many favorite genres")
+	ErrTooManyArtists        = errors.New("too many favorite artists")
+	ErrInvalidInstrument     = errors.New("invalid instrument")
+	ErrTooManyInstruments    = errors.New("too many instruments")
+	ErrListeningPrefsTooLong = errors.New("listening preferences are too long")
+)
+
+// MusicProfileService gère le profil musical des utilisateurs (genres, artistes, instruments, écoute)
+type MusicProfileService struct {
+	DB *gorm.DB
+}
+
+// NewMusicProfileService crée une nouvelle instance de MusicProfileService
+func NewMusicProfileService(db *gorm.DB) *MusicProfileService {
+	return &MusicProfileService{DB: db}
+}
+
+// MusicProfileUpdate décrit une modification partielle du profil musical ; les champs absents sont conservés
+type MusicProfileUpdate struct {
+	GenreIDs             *[]int    `json:"genre_ids"`
+	ArtistIDs            *[]int    `json:"artist_ids"`
+	Instruments          *[]string `json:"instruments"`
+	ListeningPreferences *string   `json:"listening_preferences"`
+}
+
+// withMusicProfile précharge les genres et artistes favoris des utilisateurs chargés
+func withMusicProfile(db *gorm.DB) *gorm.DB {
+	return db.Preload("FavoriteGenres").Preload("FavoriteArtists")
+}
+
+// GetGenres liste les genres pouvant être ajoutés à un profil
+func (s *MusicProfileService) GetGenres() ([]models.Genre, error) {
+	var genres []models.Genre
+	if err := s.DB.Order("name").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// GetMusicProfile retourne le profil musical d'un utilisateur
+func (s *MusicProfileService) GetMusicProfile(userID string) (models.MusicProfile, error) {
+	var user models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return models.MusicProfile{}, ErrUserNotFound
+	}
+	return user.Music(), nil
+}
+
+// UpdateMusicProfile remplace les éléments fournis du profil musical.
+// Les genres et artistes doivent exister ; les doublons sont ignorés.
+func (s *MusicProfileService) UpdateMusicProfile(userID string, update MusicProfileUpdate) (models.MusicProfile, error) {
+	var user models.Users
+	if err := s.DB.Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return models.MusicProfile{}, ErrUserNotFound
+	}
+
+	var genres []models.Genre
+	if update.GenreIDs != nil {
+		ids := uniqueIDs(*update.GenreIDs)
+		if len(ids) > maxFavoriteGenres {
+			return models.MusicProfile{}, ErrTooManyGenres
+		}
+		if len(ids) > 0 {
+			if err := s.DB.Where("id IN ?", ids).Find(&genres).Error; err != nil {
+				return models.MusicProfile{}, err
+			}
+		}
+		if len(genres) != len(ids) {
+			return models.MusicProfile{}, ErrUnknownGenre
+		}
+	}
+
+	var artists []models.Artist
+	if update.ArtistIDs != nil {
+		ids := uniqueIDs(*update.ArtistIDs)
+		if len(ids) > maxFavoriteArtists {
+			return models.MusicProfile{}, ErrTooManyArtists
+		}
+		if len(ids) > 0 {
+			if err := s.DB.Where("artist_id IN ?", ids).Find(&artists).Error; err != nil {
+				return models.MusicProfile{}, err
+			}
+		}
+		if len(artists) != len(ids) {
+			return models.MusicProfile{}, ErrUnknownArtist
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if update.Instruments != nil {
+		instruments, err := normalizeInstruments(*update.Instruments)
+		if err != nil {
+			return models.MusicProfile{}, err
+		}
+		updates["instruments"] = strings.Join(instruments, ",")
+	}
+	if update.ListeningPreferences != nil {
+		preferences := strings.TrimSpace(*update.ListeningPreferences)
+		if len([]rune(preferences)) > maxListeningPreferences {
+			return models.MusicProfile{}, ErrListeningPrefsTooLong
+		}
+		updates["listening_preferences"] = preferences
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		// Omit(".*") : seules les lignes de liaison sont écrites, les genres et artistes ne sont pas modifiés
+		if update.GenreIDs != nil {
+			if err := tx.Model(&user).Omit("FavoriteGenres.*").Association("FavoriteGenres").Replace(genres); err != nil {
+				return err
+			}
+		}
+		if update.ArtistIDs != nil {
+			if err := tx.Model(&user).Omit("FavoriteArtists.*").Association("FavoriteArtists").Replace(artists); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.MusicProfile{}, err
+	}
+
+	return s.GetMusicProfile(userID)
+}
+
+// normalizeInstruments nettoie la liste des instruments (espaces, doublons sans tenir compte de la casse)
+func normalizeInstruments(raw []string) ([]string, error) {
+	instruments := make([]string, 0, len(raw))
+	seen := make(map[string]bool)
+	for _, instrument := range raw {
+		instrument = strings.TrimSpace(instrument)
+		if instrument == "" {
+			continue
+		}
+		if strings.Contains(instrument, ",") || len([]rune(instrument)) > maxInstrumentLength {
+			return nil, ErrInvalidInstrument
+		}
+		key := strings.ToLower(instrument)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		instruments = append(instruments, instrument)
+	}
+	if len(instruments) > maxInstruments {
+		return nil, ErrTooManyInstruments
+	}
+	return instruments, nil
+}
+
+func uniqueIDs(ids []int) []int {
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
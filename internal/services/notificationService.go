@@ -103,7 +103,7 @@ func (ns *NotificationService) SendWebSocketNotification(userID, title, message
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	ns.webSocketService.broadcast <- notificationData
+	ns.webSocketService.SendToUser(userID, notificationData)
 
 	// Envoyer une notification push via FCM
 	token, err := ns.getUserFCMToken(userID)
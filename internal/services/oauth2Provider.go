@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+)
+
+// OAuth2Provider est un fournisseur OAuth2 sans OpenID Connect (ex. GitHub) :
+// l'identité est lue sur son API de profil avec le token d'accès obtenu.
+type OAuth2Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	// EmailsURL liste les adresses du compte avec leur statut de vérification (API GitHub)
+	EmailsURL  string
+	HTTPClient *http.Client
+}
+
+func (p *OAuth2Provider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (p *OAuth2Provider) oauth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+	}
+}
+
+// AuthCodeURL retourne l'URL d'autorisation avec state et challenge PKCE (S256).
+// Le nonce est propre à OpenID Connect et n'est pas transmis.
+func (p *OAuth2Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.oauth2Config().AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange échange le code puis lit le profil de l'utilisateur
+func (p *OAuth2Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient())
+	token, err := p.oauth2Config().Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	client := p.oauth2Config().Client(ctx, token)
+
+	var profile struct {
+		ID        interface{} `json:"id"`
+		Sub       string      `json:"sub"`
+		Login     string      `json:"login"`
+		Name      string      `json:"name"`
+		Email     string      `json:"email"`
+		AvatarURL string      `json:"avatar_url"`
+		Picture   string      `json:"picture"`
+	}
+	if err := getJSONWithClient(ctx, client, p.UserInfoURL, &profile); err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Subject: profile.Sub,
+		Name:    profile.Name,
+		Picture: profile.AvatarURL,
+	}
+	switch id := profile.ID.(type) {
+	case float64:
+		identity.Subject = strconv.FormatFloat(id, 'f', -1, 64)
+	case string:
+		identity.Subject = id
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("no user id in profile response")
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	if identity.Picture == "" {
+		identity.Picture = profile.Picture
+	}
+
+	// Sans liste d'adresses vérifiées, l'email du profil n'est pas considéré comme vérifié
+	if p.EmailsURL == "" {
+		identity.Email = profile.Email
+		return identity, nil
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSONWithClient(ctx, client, p.EmailsURL, &emails); err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+func getJSONWithClient(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,479 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mackenzii/freemusic/helpers"
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrInvalidOAuthState  = errors.New("invalid or expired oauth state")
+	ErrEmailNotVerified   = errors.New("email not verified by identity provider")
+	ErrIdentityInUse      = errors.New("this identity is already linked to another account")
+	ErrIdentityNotLinked  = errors.New("this identity provider is not linked to the account")
+	ErrLastLoginMethod    = errors.New("cannot unlink the only remaining login method, set a password first")
+	ErrProviderMisconfig  = errors.New("identity provider is misconfigured")
+	ErrProviderNameFormat = errors.New("invalid identity provider name")
+)
+
+// oauthStateTTL est le temps laissé à l'utilisateur pour s'authentifier auprès du fournisseur
+const oauthStateTTL = 10 * time.Minute
+
+const oauthStateKeyPrefix = "oauth_state:"
+
+// oauthState est conservé côté serveur (Redis) entre la redirection et le callback
+type oauthState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	// LinkUserID est renseigné lorsque l'utilisateur connecté relie un nouveau fournisseur à son compte
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+// OAuthResult est le résultat d'un callback : une connexion, ou la liaison d'une identité à un compte existant
+type OAuthResult struct {
+	Login  LoginResult
+	Linked bool
+}
+
+// OAuthService implémente la connexion via des fournisseurs d'identité externes
+// (flux authorization code avec state, nonce et PKCE).
+type OAuthService struct {
+	RedisClient *redis.Client
+	AuthService *AuthService
+	Providers   map[string]IdentityProvider
+}
+
+// NewOAuthService crée le service et configure les fournisseurs à partir des variables d'environnement.
+//
+// OAUTH_PROVIDERS liste les fournisseurs activés (ex. "google,apple,github,keycloak"). Pour chacun,
+// OAUTH_<NOM>_CLIENT_ID, OAUTH_<NOM>_CLIENT_SECRET, OAUTH_<NOM>_REDIRECT_URI, OAUTH_<NOM>_ISSUER
+// et OAUTH_<NOM>_SCOPES (séparés par des espaces) complètent ou remplacent les valeurs par défaut.
+// Les anciennes variables GOOGLE_* restent prises en charge.
+func NewOAuthService(redisClient *redis.Client, authService *AuthService) *OAuthService {
+	providers := make(map[string]IdentityProvider)
+
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		provider, err := newProviderFromEnv(name)
+		if err != nil {
+			log.Printf("OAuth provider %q disabled: %v", name, err)
+			continue
+		}
+		providers[name] = provider
+	}
+
+	if _, ok := providers["google"]; !ok && os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		issuer := os.Getenv("GOOGLE_ISSUER")
+		if issuer == "" {
+			issuer = "https://accounts.google.com"
+		}
+		providers["google"] = &OIDCProvider{
+			Name:         "google",
+			Issuer:       issuer,
+			ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URI"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+	}
+
+	return &OAuthService{
+		RedisClient: redisClient,
+		AuthService: authService,
+		Providers:   providers,
+	}
+}
+
+// newProviderFromEnv construit un fournisseur à partir de ses variables OAUTH_<NOM>_*.
+// Google, Apple et GitHub ont des valeurs par défaut ; tout autre nom est un fournisseur
+// OpenID Connect générique (Keycloak...) dont l'émetteur doit être fourni.
+func newProviderFromEnv(name string) (IdentityProvider, error) {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return nil, ErrProviderNameFormat
+		}
+	}
+
+	prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+	clientID := os.Getenv(prefix + "CLIENT_ID")
+	if clientID == "" {
+		return nil, ErrProviderMisconfig
+	}
+	clientSecret := os.Getenv(prefix + "CLIENT_SECRET")
+	redirectURL := os.Getenv(prefix + "REDIRECT_URI")
+	if redirectURL == "" {
+		apiURL := os.Getenv("API_URL")
+		if apiURL == "" {
+			apiURL = "http://localhost:3003"
+		}
+		redirectURL = apiURL + "/api/auth/" + name + "/callback"
+	}
+	scopes := strings.Fields(os.Getenv(prefix + "SCOPES"))
+	issuer := os.Getenv(prefix + "ISSUER")
+
+	switch name {
+	case "github":
+		if len(scopes) == 0 {
+			scopes = []string{"read:user", "user:email"}
+		}
+		return &OAuth2Provider{
+			Name:         name,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			AuthURL:      "https://github.com/login/oauth/authorize",
+			TokenURL:     "https://github.com/login/oauth/access_token",
+			UserInfoURL:  "https://api.github.com/user",
+			EmailsURL:    "https://api.github.com/user/emails",
+		}, nil
+	case "apple":
+		if issuer == "" {
+			issuer = "https://appleid.apple.com"
+		}
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "name"}
+		}
+		// Apple exige response_mode=form_post dès que les scopes email/name sont demandés :
+		// le callback est alors reçu en POST
+		return &OIDCProvider{
+			Name:         name,
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			AuthParams:   map[string]string{"response_mode": "form_post"},
+		}, nil
+	case "google":
+		if issuer == "" {
+			issuer = "https://accounts.google.com"
+		}
+	}
+
+	if issuer == "" {
+		return nil, ErrProviderMisconfig
+	}
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCProvider{
+		Name:         name,
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}, nil
+}
+
+// ProviderNames retourne la liste triée des fournisseurs activés
+func (s *OAuthService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginLogin retourne l'URL d'autorisation du fournisseur pour une connexion
+func (s *OAuthService) BeginLogin(ctx context.Context, providerName string) (string, error) {
+	return s.begin(ctx, providerName, "")
+}
+
+// BeginLink retourne l'URL d'autorisation permettant de relier un fournisseur au compte de userID
+func (s *OAuthService) BeginLink(ctx context.Context, providerName, userID string) (string, error) {
+	return s.begin(ctx, providerName, userID)
+}
+
+// begin génère state, nonce et verifier PKCE, les stocke et retourne l'URL d'autorisation du fournisseur
+func (s *OAuthService) begin(ctx context.Context, providerName, linkUserID string) (string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := helpers.GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+	data := oauthState{
+		Provider:   providerName,
+		Verifier:   oauth2.GenerateVerifier(),
+		Nonce:      nonce,
+		LinkUserID: linkUserID,
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.RedisClient.Set(ctx, oauthStateKeyPrefix+state, payload, oauthStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(ctx, state, data.Nonce, data.Verifier)
+}
+
+// Complete valide le state (à usage unique), échange le code et vérifie l'identité, puis
+// connecte l'utilisateur lié à cette identité ou, pour une liaison, la rattache au compte demandeur.
+func (s *OAuthService) Complete(ctx context.Context, providerName, state, code string, client ClientInfo) (OAuthResult, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return OAuthResult{}, ErrUnknownProvider
+	}
+	if state == "" || code == "" {
+		return OAuthResult{}, ErrInvalidOAuthState
+	}
+
+	payload, err := s.RedisClient.GetDel(ctx, oauthStateKeyPrefix+state).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return OAuthResult{}, ErrInvalidOAuthState
+		}
+		return OAuthResult{}, err
+	}
+	var data oauthState
+	if err := json.Unmarshal(payload, &data); err != nil || data.Provider != providerName {
+		return OAuthResult{}, ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Exchange(ctx, code, data.Verifier, data.Nonce)
+	if err != nil {
+		return OAuthResult{}, err
+	}
+
+	if data.LinkUserID != "" {
+		if err := s.linkToUser(providerName, identity, data.LinkUserID); err != nil {
+			return OAuthResult{}, err
+		}
+		return OAuthResult{Linked: true}, nil
+	}
+
+	user, err := s.findOrCreateUser(providerName, identity)
+	if err != nil {
+		return OAuthResult{}, err
+	}
+
+	result, err := s.AuthService.completeLogin(user, client)
+	if err != nil {
+		return OAuthResult{}, err
+	}
+	return OAuthResult{Login: result}, nil
+}
+
+// GetIdentities retourne les fournisseurs reliés au compte
+func (s *OAuthService) GetIdentities(userID string) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := s.AuthService.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity détache un fournisseur du compte. Le dernier moyen de connexion
+// (aucun mot de passe défini et une seule identité) ne peut pas être retiré.
+func (s *OAuthService) UnlinkIdentity(userID, providerName string) error {
+	return s.AuthService.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.Users
+		if err := tx.Select("id", "passwordless").Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		res := tx.Where("user_id = ? AND provider = ?", userID, providerName).Delete(&models.UserIdentity{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrIdentityNotLinked
+		}
+		// Retourner une erreur annule la suppression
+		if user.Passwordless && count-res.RowsAffected <= 0 {
+			return ErrLastLoginMethod
+		}
+		return nil
+	})
+}
+
+// linkToUser rattache l'identité au compte connecté qui a initié la liaison
+func (s *OAuthService) linkToUser(providerName string, identity *ExternalIdentity, userID string) error {
+	db := s.AuthService.DB
+
+	var existing models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", providerName, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID == userID {
+			return nil
+		}
+		return ErrIdentityInUse
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return db.Create(&models.UserIdentity{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}).Error
+}
+
+// findOrCreateUser retrouve l'utilisateur lié à l'identité externe.
+//
+// À la première connexion, l'identité est rattachée au compte existant ayant la même adresse,
+// uniquement si le fournisseur l'a vérifiée ; sinon un nouveau compte confirmé est créé.
+// Un compte local jamais confirmé a pu être créé par un tiers avant le vrai propriétaire de l'adresse :
+// il est alors réinitialisé (voir resetUnconfirmedAccount) avant d'être rattaché.
+func (s *OAuthService) findOrCreateUser(providerName string, identity *ExternalIdentity) (models.Users, error) {
+	db := s.AuthService.DB
+
+	var linked models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", providerName, identity.Subject).First(&linked).Error
+	if err == nil {
+		return s.AuthService.GetUserByID(linked.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Users{}, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return models.Users{}, ErrEmailNotVerified
+	}
+
+	var user models.Users
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("email = ?", identity.Email).First(&user).Error
+		switch {
+		case err == nil:
+			// L'adresse est prouvée par le fournisseur : le compte local peut être considéré comme confirmé
+			if !user.IsConfirmed {
+				if err := resetUnconfirmedAccount(tx, &user); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			passwordHash, err := unusablePasswordHash()
+			if err != nil {
+				return err
+			}
+			user = models.Users{
+				ID:           ulid.Make().String(),
+				Username:     usernameFromIdentity(identity),
+				Email:        identity.Email,
+				PasswordHash: passwordHash,
+				Passwordless: true,
+				ProfilePhoto: identity.Picture,
+				IsConfirmed:  true,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return models.Users{}, err
+	}
+
+	return user, nil
+}
+
+// resetUnconfirmedAccount confirme un compte local dont l'adresse vient d'être prouvée par un fournisseur.
+//
+// Rien ne garantit que le compte a été créé par le propriétaire de l'adresse : le mot de passe,
+// la double authentification, les autres identités liées, les clés d'API et les sessions
+// sont supprimés, et le compte devient sans mot de passe (réinitialisation pour en définir un).
+func resetUnconfirmedAccount(tx *gorm.DB, user *models.Users) error {
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"is_confirmed":       true,
+		"confirmation_token": "",
+		"token_expires_at":   nil,
+		"password_hash":      passwordHash,
+		"passwordless":       true,
+		"totp_enabled":       false,
+		"totp_secret":        "",
+		"tokens_revoked_at":  now,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := revokeUserSessions(tx, user.ID, now); err != nil {
+		return err
+	}
+
+	user.IsConfirmed = true
+	user.PasswordHash = passwordHash
+	user.Passwordless = true
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TokensRevokedAt = &now
+	return nil
+}
+
+// unusablePasswordHash retourne le hash d'un secret aléatoire jamais communiqué : il empêche toute
+// connexion par mot de passe tant que l'utilisateur n'en a pas défini un (réinitialisation du mot de passe)
+func unusablePasswordHash() (string, error) {
+	unusable, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return helpers.HashPassword(unusable)
+}
+
+// usernameFromIdentity propose un nom d'utilisateur à partir du profil du fournisseur
+func usernameFromIdentity(identity *ExternalIdentity) string {
+	if identity.Name != "" {
+		return identity.Name
+	}
+	return strings.SplitN(identity.Email, "@", 2)[0]
+}
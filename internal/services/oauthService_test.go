@@ -0,0 +1,344 @@
+package services_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/mackenzii/freemusic/internal/dbtest"
+	"github.com/mackenzii/freemusic/internal/oidctest"
+	"github.com/mackenzii/freemusic/internal/services"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID     = "freemusic"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:3003/api/auth/test/callback"
+)
+
+// newTestProvider starts an oidctest provider and the OIDCProvider configured against it
+func newTestProvider(t *testing.T) (*oidctest.Provider, *services.OIDCProvider) {
+	t.Helper()
+
+	server, err := oidctest.NewProvider(testClientID, testClientSecret)
+	if err != nil {
+		t.Fatalf("failed to start provider: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	return server, &services.OIDCProvider{
+		Name:         "test",
+		Issuer:       server.Issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// authorize follows the authorization URL and returns the code and state sent back to the redirect URL
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("redirected to %s, want %s", location, testRedirectURL)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCProviderPKCE(t *testing.T) {
+	server, provider := newTestProvider(t)
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	query, _ := url.Parse(authURL)
+	if query.Query().Get("code_challenge_method") != "S256" || query.Query().Get("nonce") != "nonce" {
+		t.Fatalf("authorization URL lacks PKCE or nonce: %s", authURL)
+	}
+
+	code, _ := authorize(t, authURL)
+	if _, err := provider.Exchange(ctx, code, oauth2.GenerateVerifier(), "nonce"); err == nil {
+		t.Fatal("code exchanged with the wrong PKCE verifier")
+	}
+
+	code, _ = authorize(t, authURL)
+	identity, err := provider.Exchange(ctx, code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != server.Subject || identity.Email != server.Email || !identity.EmailVerified {
+		t.Errorf("identity = %+v, want subject %q and verified email %q", identity, server.Subject, server.Email)
+	}
+
+	if _, err := provider.Exchange(ctx, code, verifier, "nonce"); err == nil {
+		t.Error("authorization code accepted twice")
+	}
+}
+
+func TestOIDCProviderNonce(t *testing.T) {
+	_, provider := newTestProvider(t)
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _ := authorize(t, authURL)
+	if _, err := provider.Exchange(ctx, code, verifier, "another nonce"); !errors.Is(err, services.ErrInvalidIDToken) {
+		t.Errorf("Exchange with another nonce: err = %v, want %v", err, services.ErrInvalidIDToken)
+	}
+}
+
+func TestOIDCProviderVerifyIDToken(t *testing.T) {
+	server, provider := newTestProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   server.Issuer(),
+			"aud":   testClientID,
+			"sub":   "user",
+			"nonce": "nonce",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	valid, err := server.SignIDToken(claims(nil))
+	if err != nil {
+		t.Fatalf("SignIDToken: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, valid, "nonce"); err != nil {
+		t.Fatalf("valid ID token rejected: %v", err)
+	}
+
+	tests := map[string]jwt.MapClaims{
+		"other issuer":       {"iss": "https://issuer.example.com"},
+		"other audience":     {"aud": "another-client"},
+		"expired":            {"exp": now.Add(-time.Minute).Unix()},
+		"missing expiration": {"exp": nil},
+		"missing subject":    {"sub": nil},
+		"other nonce":        {"nonce": "replayed"},
+	}
+	for name, changes := range tests {
+		token, err := server.SignIDToken(claims(changes))
+		if err != nil {
+			t.Fatalf("SignIDToken: %v", err)
+		}
+		if _, err := provider.VerifyIDToken(ctx, token, "nonce"); !errors.Is(err, services.ErrInvalidIDToken) {
+			t.Errorf("%s: err = %v, want %v", name, err, services.ErrInvalidIDToken)
+		}
+	}
+
+	// A token signed by a key that is not published in the JWKS
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(nil))
+	forged.Header["kid"] = "unknown"
+	token, err := forged.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, token, "nonce"); !errors.Is(err, services.ErrInvalidIDToken) {
+		t.Errorf("unknown key: err = %v, want %v", err, services.ErrInvalidIDToken)
+	}
+}
+
+func TestOAuthServiceState(t *testing.T) {
+	_, provider := newTestProvider(t)
+	_, other := newTestProvider(t)
+	db, _ := dbtest.Open(t)
+	ctx := context.Background()
+
+	service := &services.OAuthService{
+		RedisClient: newTestRedis(t),
+		AuthService: services.NewAuthService(db, nil, nil, nil),
+		Providers:   map[string]services.IdentityProvider{"test": provider, "other": other},
+	}
+
+	authURL, err := service.BeginLink(ctx, "test", "01HZY5Q3J8W4Z0V7C9K2N6M1PB")
+	if err != nil {
+		t.Fatalf("BeginLink: %v", err)
+	}
+	code, state := authorize(t, authURL)
+	if state == "" {
+		t.Fatal("no state in the authorization URL")
+	}
+
+	if _, err := service.Complete(ctx, "test", "forged", code, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidOAuthState) {
+		t.Errorf("unknown state: err = %v, want %v", err, services.ErrInvalidOAuthState)
+	}
+
+	result, err := service.Complete(ctx, "test", state, code, services.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !result.Linked {
+		t.Error("identity not linked")
+	}
+
+	if _, err := service.Complete(ctx, "test", state, code, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidOAuthState) {
+		t.Errorf("reused state: err = %v, want %v", err, services.ErrInvalidOAuthState)
+	}
+
+	// A state issued for one provider cannot complete the flow of another
+	authURL, err = service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state = authorize(t, authURL)
+	if _, err := service.Complete(ctx, "other", state, code, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidOAuthState) {
+		t.Errorf("state of another provider: err = %v, want %v", err, services.ErrInvalidOAuthState)
+	}
+}
+
+// newTestRedis starts a minimal in-memory Redis server supporting the commands used for OAuth states
+// (SET, GET, GETDEL, DEL) and returns a client connected to it
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mutex sync.Mutex
+	values := make(map[string]string)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveRedis(conn, &mutex, values)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func serveRedis(conn net.Conn, mutex *sync.Mutex, values map[string]string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+
+		mutex.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			reply = "+PONG\r\n"
+		case "SET":
+			values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "GET", "GETDEL":
+			value, ok := values[args[1]]
+			if strings.EqualFold(args[0], "GETDEL") {
+				delete(values, args[1])
+			}
+			reply = "$-1\r\n"
+			if ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			}
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := values[key]; ok {
+					delete(values, key)
+					deleted++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", deleted)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		mutex.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readRedisCommand reads a command sent as an array of bulk strings
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrUnknownKeyID   = errors.New("unknown signing key")
+)
+
+// ExternalIdentity est l'identité retournée par un fournisseur après authentification
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// IdentityProvider est un fournisseur d'identité utilisable pour se connecter (OpenID Connect ou OAuth2)
+type IdentityProvider interface {
+	// AuthCodeURL retourne l'URL d'autorisation avec state, nonce et challenge PKCE (S256)
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange échange le code d'autorisation et retourne l'identité vérifiée de l'utilisateur
+	Exchange(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error)
+}
+
+// jwksRefreshInterval limite la fréquence de rechargement des clés lorsqu'un kid inconnu est présenté
+const jwksRefreshInterval = time.Minute
+
+// OIDCProvider est un fournisseur OpenID Connect configuré par découverte
+// (/.well-known/openid-configuration) et dont les ID tokens sont vérifiés via ses clés JWKS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	// AuthParams sont ajoutés à l'URL d'autorisation (ex. response_mode=form_post pour Apple)
+	AuthParams map[string]string
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims contient les claims utilisés pour identifier l'utilisateur
+type IDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// UnmarshalJSON accepte email_verified sous forme de booléen ou de chaîne, certains fournisseurs utilisant "true"
+func (c *IDTokenClaims) UnmarshalJSON(data []byte) error {
+	type plain IDTokenClaims
+	var raw struct {
+		plain
+		EmailVerified interface{} `json:"email_verified"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = IDTokenClaims(raw.plain)
+	switch v := raw.EmailVerified.(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified = v == "true"
+	}
+	return nil
+}
+
+func (p *OIDCProvider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// getDiscovery charge (une seule fois) le document de découverte du fournisseur
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc oidcDiscovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+	if doc.Issuer != p.Issuer {
+		return nil, fmt.Errorf("issuer mismatch in discovery document: %q", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("incomplete discovery document")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// oauth2Config construit la configuration du flux authorization code à partir de la découverte
+func (p *OIDCProvider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}, nil
+}
+
+// AuthCodeURL retourne l'URL d'autorisation avec state, nonce et challenge PKCE (S256)
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	config, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	opts := []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.S256ChallengeOption(verifier),
+	}
+	for k, v := range p.AuthParams {
+		opts = append(opts, oauth2.SetAuthURLParam(k, v))
+	}
+	return config.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange échange le code d'autorisation contre des tokens et retourne l'identité issue de l'ID token vérifié
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	config, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient())
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("no id_token in token response")
+	}
+
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExternalIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// VerifyIDToken vérifie la signature (RS256, clés JWKS), l'émetteur, l'audience, l'expiration et le nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Issuer != p.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing expiration", ErrInvalidIDToken)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// publicKey retourne la clé RSA correspondant au kid, en rechargeant le JWKS si elle est inconnue
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.keysFetchedAt) > jwksRefreshInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrUnknownKeyID
+	}
+
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, doc.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKeyID
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	return getJSONWithClient(ctx, p.httpClient(), url, v)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mackenzii/freemusic/internal/models"
 	openai "github.com/sashabaranov/go-openai"
@@ -25,15 +26,25 @@ func NewOpenAIService() *OpenAIService {
 	}
 }
 
-// SuggestConcerts suggère des concerts ou des événements en fonction des préférences musicales des utilisateurs
+// SuggestConcerts suggère des concerts ou des événements en fonction des préférences musicales des utilisateurs.
+// Les genres et artistes favoris doivent avoir été préchargés (FavoriteGenres, FavoriteArtists).
 func (s *OpenAIService) SuggestConcerts(users []models.Users) ([]string, error) {
 	var suggestions []string
 
 	// Préparation du prompt pour OpenAI basé sur les préférences musicales des utilisateurs
 	prompt := "Voici les préférences musicales de certains utilisateurs :\n"
 	for _, user := range users {
-		prompt += fmt.Sprintf("Utilisateur: %s, Genre préféré: %s, Artiste préféré: %s, Niveau de compétence: %s, Biographie: %s\n",
-			user.Username, user.FavoriteSport, user.FavoriteSport, user.SkillLevel, user.Bio)
+		music := user.Music()
+		genres := make([]string, 0, len(music.FavoriteGenres))
+		for _, genre := range music.FavoriteGenres {
+			genres = append(genres, genre.Name)
+		}
+		artists := make([]string, 0, len(music.FavoriteArtists))
+		for _, artist := range music.FavoriteArtists {
+			artists = append(artists, artist.Name)
+		}
+		prompt += fmt.Sprintf("Utilisateur: %s, Genres préférés: %s, Artistes préférés: %s, Instruments pratiqués: %s, Préférences d'écoute: %s, Biographie: %s\n",
+			user.Username, strings.Join(genres, ", "), strings.Join(artists, ", "), strings.Join(music.Instruments, ", "), music.ListeningPreferences, user.Bio)
 	}
 	prompt += "Sur la base de ces informations, suggère des concerts ou événements musicaux adaptés, ainsi que des artistes à suivre. Par exemple : 'Concert de l'artiste XYZ à Paris le 20 janvier'."
 
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxApplicationDocuments   = 5
+	maxApplicationDocument    = 10 << 20 // 10 Mo par justificatif
+	maxOrganizationName       = 200
+	maxApplicationDescription = 2000
+)
+
+// applicationDocumentTypes liste les extensions acceptées pour les justificatifs
+var applicationDocumentTypes = map[string]bool{".pdf": true, ".jpg": true, ".jpeg": true, ".png": true}
+
+var (
+	ErrApplicationNotFound      = errors.New("organizer application not found")
+	ErrApplicationPending       = errors.New("an organizer application is already pending")
+	ErrApplicationReviewed      = errors.New("organizer application has already been reviewed")
+	ErrAlreadyVerifiedOrganizer = errors.New("account is already a verified organizer")
+	ErrOrganizationRequired     = errors.New("organization name is required")
+	ErrOrganizationTooLong      = errors.New("organization name is too long")
+	ErrDescriptionTooLong       = errors.New("description is too long")
+	ErrInvalidWebsite           = errors.New("website must be an http(s) URL")
+	ErrDocumentsRequired        = errors.New("at least one supporting document is required")
+	ErrTooManyDocuments         = errors.New("too many supporting documents")
+	ErrInvalidDocument          = errors.New("supporting documents must be PDF, JPEG or PNG files of at most 10 MB")
+	ErrDocumentNotFound         = errors.New("document not found")
+	ErrInvalidApplicationStatus = errors.New("status must be one of: pending, approved, rejected")
+)
+
+// OrganizerApplicationService gère les demandes de statut d'organisateur et leur validation par les administrateurs
+type OrganizerApplicationService struct {
+	DB           *gorm.DB
+	ImageService *ImageService
+	EmailService *EmailService
+}
+
+// NewOrganizerApplicationService crée une nouvelle instance de OrganizerApplicationService
+func NewOrganizerApplicationService(db *gorm.DB, imageService *ImageService, emailService *EmailService) *OrganizerApplicationService {
+	return &OrganizerApplicationService{
+		DB:           db,
+		ImageService: imageService,
+		EmailService: emailService,
+	}
+}
+
+// OrganizerApplicationRequest décrit les informations fournies par le demandeur
+type OrganizerApplicationRequest struct {
+	Organization string
+	Website      string
+	Description  string
+}
+
+// Apply enregistre une demande de statut d'organisateur et ses justificatifs.
+// Une seule demande peut être en attente à la fois ; une demande refusée peut être renouvelée.
+func (s *OrganizerApplicationService) Apply(userID string, req OrganizerApplicationRequest, documents []*multipart.FileHeader) (*models.OrganizerApplication, error) {
+	var user models.Users
+	if err := s.DB.Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsVerifiedOrganizer() {
+		return nil, ErrAlreadyVerifiedOrganizer
+	}
+
+	req.Organization = strings.TrimSpace(req.Organization)
+	req.Website = strings.TrimSpace(req.Website)
+	req.Description = strings.TrimSpace(req.Description)
+	if err := validateApplication(req, documents); err != nil {
+		return nil, err
+	}
+
+	var pending int64
+	if err := s.DB.Model(&models.OrganizerApplication{}).
+		Where("user_id = ? AND status = ?", userID, models.ApplicationPending).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrApplicationPending
+	}
+
+	files := make([]string, 0, len(documents))
+	for _, document := range documents {
+		name, err := s.ImageService.SaveImage(document)
+		if err != nil {
+			s.removeDocuments(files)
+			return nil, err
+		}
+		files = append(files, name)
+	}
+
+	application := models.OrganizerApplication{
+		ID:           ulid.Make().String(),
+		UserID:       userID,
+		Organization: req.Organization,
+		Website:      req.Website,
+		Description:  req.Description,
+		Documents:    strings.Join(files, ","),
+		Status:       models.ApplicationPending,
+	}
+	if err := s.DB.Create(&application).Error; err != nil {
+		s.removeDocuments(files)
+		return nil, err
+	}
+
+	application.Files = files
+	return &application, nil
+}
+
+// GetUserApplications liste les demandes d'un utilisateur, de la plus récente à la plus ancienne
+func (s *OrganizerApplicationService) GetUserApplications(userID string) ([]models.OrganizerApplication, error) {
+	var applications []models.OrganizerApplication
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&applications).Error; err != nil {
+		return nil, err
+	}
+	return withDocumentFiles(applications), nil
+}
+
+// GetApplications liste les demandes pour les administrateurs, les plus anciennes d'abord.
+// Sans statut, seules les demandes en attente sont retournées.
+func (s *OrganizerApplicationService) GetApplications(status models.ApplicationStatus) ([]models.OrganizerApplication, error) {
+	if status == "" {
+		status = models.ApplicationPending
+	}
+	if status != models.ApplicationPending && status != models.ApplicationApproved && status != models.ApplicationRejected {
+		return nil, ErrInvalidApplicationStatus
+	}
+
+	var applications []models.OrganizerApplication
+	if err := s.DB.Where("status = ?", status).Order("created_at").Find(&applications).Error; err != nil {
+		return nil, err
+	}
+	return withDocumentFiles(applications), nil
+}
+
+// Approve valide une demande : le demandeur devient organisateur (un administrateur garde son rôle)
+// et obtient le badge d'organisateur vérifié affiché sur ses événements.
+func (s *OrganizerApplicationService) Approve(actor AuditActor, applicationID, note string) (*models.OrganizerApplication, error) {
+	application, user, err := s.loadPending(actor, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	role := user.Role
+	if role != models.RoleAdmin {
+		role = models.RoleOrganizer
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := reviewApplication(tx, application, actor, models.ApplicationApproved, note, now); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"role":                  role,
+			"organizer_verified_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditOrganizerApproved, user.ID, note, map[string]interface{}{
+			"application_id": application.ID,
+			"organization":   application.Organization,
+			"previous_role":  user.Role,
+			"role":           role,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyApplicant(user, NotificationEmail{
+		Subject: "Votre demande d'organisateur a été acceptée",
+		Heading: "Vous êtes désormais organisateur vérifié",
+		Paragraphs: []string{
+			"Votre demande pour " + application.Organization + " a été validée.",
+			"Le badge d'organisateur vérifié apparaît désormais sur vos événements.",
+		},
+	})
+	application.Files = application.DocumentList()
+	return application, nil
+}
+
+// Reject refuse une demande avec un motif communiqué au demandeur
+func (s *OrganizerApplicationService) Reject(actor AuditActor, applicationID, reason string) (*models.OrganizerApplication, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	application, user, err := s.loadPending(actor, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := reviewApplication(tx, application, actor, models.ApplicationRejected, reason, time.Now()); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditOrganizerRejected, user.ID, reason, map[string]interface{}{
+			"application_id": application.ID,
+			"organization":   application.Organization,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyApplicant(user, NotificationEmail{
+		Subject: "Votre demande d'organisateur a été refusée",
+		Heading: "Votre demande d'organisateur n'a pas été retenue",
+		Paragraphs: []string{
+			"Motif : " + reason,
+			"Vous pouvez déposer une nouvelle demande avec des informations complémentaires.",
+		},
+	})
+	application.Files = application.DocumentList()
+	return application, nil
+}
+
+// DocumentPath retourne le chemin d'un justificatif d'une demande, pour consultation par un administrateur
+func (s *OrganizerApplicationService) DocumentPath(applicationID, name string) (string, error) {
+	var application models.OrganizerApplication
+	if err := s.DB.Where("id = ?", applicationID).First(&application).Error; err != nil {
+		return "", ErrApplicationNotFound
+	}
+	for _, file := range application.DocumentList() {
+		if file == name && filepath.Base(name) == name {
+			return filepath.Join(s.ImageService.UploadDir, name), nil
+		}
+	}
+	return "", ErrDocumentNotFound
+}
+
+// loadPending charge une demande en attente et son auteur, qui doit encore exister
+func (s *OrganizerApplicationService) loadPending(actor AuditActor, applicationID string) (*models.OrganizerApplication, models.Users, error) {
+	var application models.OrganizerApplication
+	if err := s.DB.Where("id = ?", applicationID).First(&application).Error; err != nil {
+		return nil, models.Users{}, ErrApplicationNotFound
+	}
+	if application.Status != models.ApplicationPending {
+		return nil, models.Users{}, ErrApplicationReviewed
+	}
+	if application.UserID == actor.ID {
+		return nil, models.Users{}, ErrCannotModerateSelf
+	}
+
+	var user models.Users
+	if err := s.DB.Where("id = ? AND deleted_at IS NULL", application.UserID).First(&user).Error; err != nil {
+		return nil, models.Users{}, ErrUserNotFound
+	}
+	return &application, user, nil
+}
+
+// reviewApplication enregistre la décision ; la condition sur le statut évite qu'une demande soit traitée deux fois
+func reviewApplication(tx *gorm.DB, application *models.OrganizerApplication, actor AuditActor, status models.ApplicationStatus, note string, now time.Time) error {
+	res := tx.Model(&models.OrganizerApplication{}).
+		Where("id = ? AND status = ?", application.ID, models.ApplicationPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewer_id": actor.ID,
+			"review_note": note,
+			"reviewed_at": now,
+			"updated_at":  now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrApplicationReviewed
+	}
+
+	application.Status = status
+	application.ReviewerID = actor.ID
+	application.ReviewNote = note
+	application.ReviewedAt = &now
+	application.UpdatedAt = now
+	return nil
+}
+
+// notifyApplicant informe le demandeur de la décision, sans bloquer la requête
+func (s *OrganizerApplicationService) notifyApplicant(user models.Users, email NotificationEmail) {
+	email.ToEmail = user.Email
+	go func() {
+		if err := s.EmailService.SendNotificationEmail(email); err != nil {
+			log.Printf("Error sending organizer application decision to %s: %v", user.ID, err)
+		}
+	}()
+}
+
+// removeDocuments supprime les justificatifs déjà enregistrés lorsqu'une demande échoue
+func (s *OrganizerApplicationService) removeDocuments(files []string) {
+	for _, name := range files {
+		if err := os.Remove(filepath.Join(s.ImageService.UploadDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove application document %s: %v", name, err)
+		}
+	}
+}
+
+// validateApplication vérifie les informations et les justificatifs d'une demande
+func validateApplication(req OrganizerApplicationRequest, documents []*multipart.FileHeader) error {
+	if req.Organization == "" {
+		return ErrOrganizationRequired
+	}
+	if len([]rune(req.Organization)) > maxOrganizationName {
+		return ErrOrganizationTooLong
+	}
+	if len([]rune(req.Description)) > maxApplicationDescription {
+		return ErrDescriptionTooLong
+	}
+	if req.Website != "" {
+		website, err := url.Parse(req.Website)
+		if err != nil || (website.Scheme != "http" && website.Scheme != "https") || website.Host == "" {
+			return ErrInvalidWebsite
+		}
+	}
+
+	if len(documents) == 0 {
+		return ErrDocumentsRequired
+	}
+	if len(documents) > maxApplicationDocuments {
+		return ErrTooManyDocuments
+	}
+	for _, document := range documents {
+		if document.Size > maxApplicationDocument || !applicationDocumentTypes[strings.ToLower(filepath.Ext(document.Filename))] {
+			return ErrInvalidDocument
+		}
+	}
+	return nil
+}
+
+func withDocumentFiles(applications []models.OrganizerApplication) []models.OrganizerApplication {
+	for i := range applications {
+		applications[i].Files = applications[i].DocumentList()
+	}
+	return applications
+}
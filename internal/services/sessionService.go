@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mackenzii/freemusic/helpers"
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limite les écritures de last_seen_at lors des requêtes authentifiées
+const sessionTouchInterval = 5 * time.Minute
+
+// ClientInfo décrit l'appareil à l'origine d'une connexion
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// DeviceName est le nom choisi par le client (en-tête X-Device-Name) ; à défaut il est déduit du User-Agent
+	DeviceName string
+}
+
+func (ci ClientInfo) deviceLabel() string {
+	if ci.DeviceName != "" {
+		return truncate(ci.DeviceName, 100)
+	}
+	return helpers.DeviceLabel(ci.UserAgent)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// createSession enregistre la session ouverte par une connexion.
+// newDevice indique qu'elle provient d'un appareil jamais vu sur le compte : les appareils sont comparés
+// par navigateur et système (helpers.DeviceLabel), pour qu'une mise à jour du navigateur ne déclenche pas d'alerte ;
+// la toute première connexion du compte n'est pas considérée comme un nouvel appareil.
+func (s *AuthService) createSession(tx *gorm.DB, userID, sessionID string, client ClientInfo) (session models.Session, newDevice bool, err error) {
+	var userAgents []string
+	if err := tx.Model(&models.Session{}).Where("user_id = ?", userID).
+		Distinct("user_agent").Pluck("user_agent", &userAgents).Error; err != nil {
+		return models.Session{}, false, err
+	}
+	device := helpers.DeviceLabel(client.UserAgent)
+	knownDevice := false
+	for _, userAgent := range userAgents {
+		if helpers.DeviceLabel(userAgent) == device {
+			knownDevice = true
+			break
+		}
+	}
+
+	now := time.Now()
+	session = models.Session{
+		ID:          sessionID,
+		UserID:      userID,
+		DeviceLabel: client.deviceLabel(),
+		UserAgent:   truncate(client.UserAgent, 512),
+		IP:          client.IP,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(middlewares.RefreshTokenTTL),
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return models.Session{}, false, err
+	}
+
+	return session, len(userAgents) > 0 && !knownDevice, nil
+}
+
+// notifyNewDevice envoie en arrière-plan une alerte de connexion depuis un nouvel appareil
+func (s *AuthService) notifyNewDevice(userID string, session models.Session) {
+	go func() {
+		user, err := s.GetUserByID(userID)
+		if err != nil {
+			log.Printf("Error loading user for new device alert: %v", err)
+			return
+		}
+		if err := s.EmailService.SendNotificationEmail(NotificationEmail{
+			ToEmail: user.Email,
+			Subject: "Nouvelle connexion à votre compte",
+			Heading: "Connexion depuis un nouvel appareil",
+			Paragraphs: []string{
+				"Une connexion à votre compte a eu lieu depuis un appareil que nous ne connaissions pas.",
+				"Appareil : " + session.DeviceLabel,
+				"Adresse IP : " + session.IP,
+				"Date : " + session.CreatedAt.Format("02/01/2006 15:04"),
+				"Si ce n'est pas vous, révoquez cette session depuis vos paramètres et changez votre mot de passe.",
+			},
+		}); err != nil {
+			log.Printf("Error sending new device alert: %v", err)
+		}
+	}()
+}
+
+// touchSession met à jour l'activité d'une session lors d'un rafraîchissement
+func (s *AuthService) touchSession(tx *gorm.DB, sessionID, ip string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   now.Add(middlewares.RefreshTokenTTL),
+	}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	return tx.Model(&models.Session{}).Where("id = ?", sessionID).Updates(updates).Error
+}
+
+// GetSessions retourne les sessions actives de l'utilisateur, la plus récemment utilisée en premier.
+// currentSessionID marque la session de l'appelant.
+func (s *AuthService) GetSessions(userID, currentSessionID string) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := s.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession déconnecte un appareil de l'utilisateur
+func (s *AuthService) RevokeSession(userID, sessionID string) error {
+	var session models.Session
+	if err := s.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return s.revokeFamily(session.ID)
+}
+
+// RevokeOtherSessions déconnecte tous les appareils de l'utilisateur sauf celui de l'appelant
+func (s *AuthService) RevokeOtherSessions(userID, currentSessionID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// revokeUserSessions révoque les sessions et refresh tokens encore actifs d'un utilisateur
+func revokeUserSessions(tx *gorm.DB, userID string, now time.Time) error {
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mackenzii/freemusic/helpers"
+	middlewares "github.com/mackenzii/freemusic/internal/middleware"
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+)
+
+// RecoveryCodeCount est le nombre de codes de secours générés à l'activation
+const RecoveryCodeCount = 10
+
+// totpSkew est la tolérance de décalage d'horloge, en périodes de 30 secondes
+const totpSkew = 1
+
+// TwoFactorSetup contient le secret TOTP et l'URI otpauth:// à afficher sous forme de QR code
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// SetupTwoFactor génère un nouveau secret TOTP pour l'utilisateur.
+// La double authentification n'est active qu'après confirmation d'un premier code (EnableTwoFactor).
+func (s *AuthService) SetupTwoFactor(userID string) (TwoFactorSetup, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	if user.TOTPEnabled {
+		return TwoFactorSetup{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	if err := s.DB.Model(&models.Users{}).Where("id = ?", user.ID).Update("totp_secret", secret).Error; err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "FreeMusic"
+	}
+	return TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: helpers.TOTPProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor active la double authentification après vérification d'un code
+// et retourne les codes de secours, affichés une seule fois.
+func (s *AuthService) EnableTwoFactor(userID, code string) ([]string, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := helpers.ValidateTOTP(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor désactive la double authentification ; le mot de passe et un code valide sont exigés
+func (s *AuthService) DisableTwoFactor(userID, password, code string) error {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if !helpers.CheckPasswordHash(password, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	if err := s.verifySecondFactor(&user, code); err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Users{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes invalide les anciens codes de secours et en génère de nouveaux
+func (s *AuthService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifySecondFactor(&user, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyTwoFactorLogin termine une connexion : le ChallengeToken retourné par Login
+// est échangé, avec un code TOTP ou un code de secours, contre l'accessToken et le refreshToken.
+// Les codes erronés sont comptés comme des échecs de connexion.
+func (s *AuthService) VerifyTwoFactorLogin(challengeToken, code string, client ClientInfo) (LoginResult, error) {
+	ip := client.IP
+	claims, err := middlewares.ParseToken(challengeToken)
+	if err != nil || claims.TokenType != middlewares.TokenTypeMFAChallenge {
+		return LoginResult{}, ErrInvalidChallenge
+	}
+
+	user, err := s.GetUserByID(claims.UserID.String())
+	if err != nil {
+		return LoginResult{}, ErrInvalidChallenge
+	}
+	if !user.TOTPEnabled {
+		return LoginResult{}, ErrInvalidChallenge
+	}
+	if err := s.LoginGuard.Check(user.Email, ip); err != nil {
+		return LoginResult{}, err
+	}
+	if err := s.verifySecondFactor(&user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.registerLoginFailure(user, ip)
+		}
+		return LoginResult{}, err
+	}
+	if err := s.LoginGuard.Reset(user.Email); err != nil {
+		log.Printf("Error resetting login failures: %v", err)
+	}
+	if err := suspensionError(user); err != nil {
+		return LoginResult{}, err
+	}
+
+	accessToken, refreshToken, err := s.IssueTokens(user.ID, client)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// verifySecondFactor accepte un code TOTP (une seule fois par période) ou un code de secours non utilisé
+func (s *AuthService) verifySecondFactor(user *models.Users, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if step, ok := helpers.ValidateTOTP(user.TOTPSecret, code, time.Now(), totpSkew); ok {
+		// Mise à jour conditionnelle : un code déjà utilisé (ou plus ancien) est refusé
+		res := s.DB.Model(&models.Users{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	res := s.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, helpers.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes supprime les codes de secours existants et en enregistre de nouveaux
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw, err := helpers.GenerateSecureToken(8)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:8] + "-" + raw[8:]
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: helpers.HashToken(normalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignore la casse et les tirets saisis par l'utilisateur
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
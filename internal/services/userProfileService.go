@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/mackenzii/freemusic/internal/models"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrInvalidVisibility = errors.New("visibility must be one of: everyone, friends, nobody")
+)
+
+// PrivacySettingsUpdate décrit une modification partielle des réglages de visibilité
+type PrivacySettingsUpdate struct {
+	EmailVisibility     *models.ProfileVisibility `json:"email_visibility"`
+	LocationVisibility  *models.ProfileVisibility `json:"location_visibility"`
+	BirthDateVisibility *models.ProfileVisibility `json:"birth_date_visibility"`
+	BioVisibility       *models.ProfileVisibility `json:"bio_visibility"`
+}
+
+// GetProfile charge un utilisateur avec son profil musical, pour la vue complète de son propre profil
+func (s *AuthService) GetProfile(userID string) (models.Users, error) {
+	var user models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return models.Users{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// GetPublicUsers retourne les profils publics des comptes actifs vus par viewerID, hors utilisateurs bloqués dans un sens ou dans l'autre
+func (s *AuthService) GetPublicUsers(viewerID string) ([]models.PublicUser, error) {
+	var users []models.Users
+	if err := s.DB.Scopes(withMusicProfile, notBlockedWith(viewerID, "users.id")).Where("deleted_at IS NULL").Order("username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return s.PublicProfiles(viewerID, users)
+}
+
+// GetPublicProfile retourne le profil d'un utilisateur tel que viewerID est autorisé à le voir
+func (s *AuthService) GetPublicProfile(viewerID, userID string) (models.PublicUser, error) {
+	var user models.Users
+	if err := s.DB.Scopes(withMusicProfile).Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return models.PublicUser{}, ErrUserNotFound
+	}
+
+	profiles, err := s.PublicProfiles(viewerID, []models.Users{user})
+	if err != nil {
+		return models.PublicUser{}, err
+	}
+	return profiles[0], nil
+}
+
+// PublicProfiles convertit des utilisateurs en profils publics pour viewerID.
+// Les liens d'amitié sont résolus en une seule requête ; le profil de l'appelant lui-même
+// est renvoyé sans masquage.
+func (s *AuthService) PublicProfiles(viewerID string, users []models.Users) ([]models.PublicUser, error) {
+	friends, err := s.friendIDs(viewerID, users)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]models.PublicUser, 0, len(users))
+	for _, user := range users {
+		if user.ID == viewerID {
+			profiles = append(profiles, user.OwnPublic())
+			continue
+		}
+		profiles = append(profiles, user.Public(friends[user.ID]))
+	}
+	return profiles, nil
+}
+
+// friendIDs retourne, parmi users, les amis (demande acceptée) de viewerID
+func (s *AuthService) friendIDs(viewerID string, users []models.Users) (map[string]bool, error) {
+	friends := make(map[string]bool)
+	if viewerID == "" || len(users) == 0 {
+		return friends, nil
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	var requests []models.FriendRequest
+	if err := s.DB.Select("sender_id", "receiver_id").
+		Where("status = ?", "accepted").
+		Where("(sender_id = ? AND receiver_id IN ?) OR (receiver_id = ? AND sender_id IN ?)", viewerID, ids, viewerID, ids).
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	for _, request := range requests {
+		if request.SenderId == viewerID {
+			friends[request.ReceiverId] = true
+		} else {
+			friends[request.SenderId] = true
+		}
+	}
+	return friends, nil
+}
+
+// UpdatePrivacySettings modifie les réglages de visibilité fournis et retourne le profil mis à jour
+func (s *AuthService) UpdatePrivacySettings(userID string, update PrivacySettingsUpdate) (models.Users, error) {
+	updates := map[string]interface{}{}
+	fields := []struct {
+		column string
+		value  *models.ProfileVisibility
+	}{
+		{"email_visibility", update.EmailVisibility},
+		{"location_visibility", update.LocationVisibility},
+		{"birth_date_visibility", update.BirthDateVisibility},
+		{"bio_visibility", update.BioVisibility},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		if !field.value.Valid() {
+			return models.Users{}, ErrInvalidVisibility
+		}
+		updates[field.column] = *field.value
+	}
+
+	if len(updates) > 0 {
+		res := s.DB.Model(&models.Users{}).Where("id = ? AND deleted_at IS NULL", userID).Updates(updates)
+		if res.Error != nil {
+			return models.Users{}, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.Users{}, ErrUserNotFound
+		}
+	}
+
+	return s.GetUserByID(userID)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"log"
+
+	"github.com/mackenzii/freemusic/internal/models"
+	"gorm.io/gorm"
+)
+
+// MigrateMusicProfiles insère le catalogue de genres, puis reporte les anciennes données du profil sportif
+// vers le profil musical. À appeler après AutoMigrate, qui crée la table de liaison user_favorite_genres.
+//
+// Un favorite_sport correspondant au nom d'un genre (sans tenir compte de la casse) devient un genre favori.
+// Les autres valeurs, et skill_level, n'ont pas d'équivalent musical : les colonnes ne sont pas supprimées
+// mais renommées en legacy_favorite_sport et legacy_skill_level, à supprimer dans une version ultérieure
+// une fois les données vérifiées. Le report n'a lieu qu'une fois (les colonnes d'origine n'existent plus ensuite).
+func MigrateMusicProfiles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range models.GenreCatalogue {
+			if err := tx.Exec(`INSERT INTO genres (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, name).Error; err != nil {
+				return err
+			}
+		}
+
+		migrator := tx.Migrator()
+		if migrator.HasColumn(&models.Users{}, "favorite_sport") {
+			res := tx.Exec(`INSERT INTO user_favorite_genres (user_id, genre_id)
+				SELECT users.id, genres.id FROM users
+				JOIN genres ON LOWER(genres.name) = LOWER(TRIM(users.favorite_sport))
+				ON CONFLICT DO NOTHING`)
+			if res.Error != nil {
+				return res.Error
+			}
+			var unmatched int64
+			if err := tx.Raw(`SELECT COUNT(*) FROM users
+				WHERE TRIM(COALESCE(favorite_sport, '')) <> ''
+				AND NOT EXISTS (SELECT 1 FROM genres WHERE LOWER(genres.name) = LOWER(TRIM(users.favorite_sport)))`).
+				Scan(&unmatched).Error; err != nil {
+				return err
+			}
+			log.Printf("Music profile migration: %d favorite genres carried over from favorite_sport, %d values kept in legacy_favorite_sport only",
+				res.RowsAffected, unmatched)
+
+			if err := migrator.RenameColumn(&models.Users{}, "favorite_sport", "legacy_favorite_sport"); err != nil {
+				return err
+			}
+		}
+		if migrator.HasColumn(&models.Users{}, "skill_level") {
+			if err := migrator.RenameColumn(&models.Users{}, "skill_level", "legacy_skill_level"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateEmailConfirmation confirme les comptes créés avant que la confirmation de l'adresse ne soit exigée
+// à la connexion, pour ne pas les bloquer du jour au lendemain. Ils sont reconnus à l'absence de la colonne
+// confirmation_sent_at, introduite en même temps : à appeler avant AutoMigrate.
+// Sans effet une fois la colonne créée. Un compte créé ensuite et resté non confirmé reçoit un nouveau lien
+// via POST /api/resend_confirmation.
+func MigrateEmailConfirmation(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Users{}) || migrator.HasColumn(&models.Users{}, "confirmation_sent_at") {
+		return nil
+	}
+
+	res := db.Exec(`UPDATE users SET is_confirmed = TRUE WHERE is_confirmed IS NOT TRUE`)
+	if res.Error != nil {
+		return res.Error
+	}
+	log.Printf("Email confirmation migration: %d existing accounts marked as confirmed", res.RowsAffected)
+	return nil
+}
+
+// MigrateFriendRequests garantit qu'une paire d'utilisateurs n'a qu'une seule ligne dans friend_requests,
+// quel que soit le sens de la demande. Les doublons existants sont d'abord supprimés en gardant
+// l'amitié acceptée, sinon la demande en attente, sinon la plus récente.
+// Sans effet une fois l'index créé.
+func MigrateFriendRequests(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&models.FriendRequest{}, "idx_friend_requests_pair") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(`DELETE FROM friend_requests WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY LEAST(sender_id, receiver_id), GREATEST(sender_id, receiver_id)
+					ORDER BY CASE status WHEN 'accepted' THEN 0 WHEN 'pending' THEN 1 ELSE 2 END, updated_at DESC, id DESC
+				) AS position FROM friend_requests
+			) ranked WHERE position > 1)`)
+		if res.Error != nil {
+			return res.Error
+		}
+		log.Printf("Friend requests migration: %d duplicate rows removed", res.RowsAffected)
+
+		return tx.Exec(`CREATE UNIQUE INDEX idx_friend_requests_pair
+			ON friend_requests (LEAST(sender_id, receiver_id), GREATEST(sender_id, receiver_id))`).Error
+	})
+}
@@ -42,6 +42,7 @@ func main() {
 
 		&models.Users{},
 		&models.Artist{},
+		&models.Genre{},
 		&models.Category{},
 		&models.Event{},
 	)
@@ -53,6 +54,7 @@ func main() {
 	log.Println("Generating fixtures...")
 	fixtures.GenerateUsers(db)
 	fixtures.GenerateArtists(db)
+	fixtures.GenerateGenres(db)
 	fixtures.GenerateCategories(db)
 	fixtures.GenerateEvents(db)
 	log.Println("Fixtures generated successfully!")